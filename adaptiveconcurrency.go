@@ -0,0 +1,242 @@
+package pyExecuter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAdaptiveInterval = 5 * time.Second // 并发控制器默认的采样间隔
+	DefaultMemoryHeavyBytes = 1 << 30         // 默认的内存密集型任务阈值
+)
+
+// HostLoad 主机负载的一次采样
+type HostLoad struct {
+	CPUs              int     // 可用的 CPU 数
+	Load1             float64 // 1 分钟平均负载
+	MemTotalBytes     uint64  // 内存总量
+	MemAvailableBytes uint64  // 可用内存（MemAvailable）
+	PSI               bool    // 内核是否提供 PSI，为 false 时下列两项为零
+	CPUPressure       float64 // CPU 压力：最近 10 秒内有任务等待 CPU 的时间百分比（some avg10）
+	MemoryPressure    float64 // 内存压力：最近 10 秒内有任务等待内存的时间百分比（some avg10）
+}
+
+// LoadPerCPU 返回每个 CPU 的 1 分钟平均负载
+func (l HostLoad) LoadPerCPU() float64 {
+	if l.CPUs <= 0 {
+		return l.Load1
+	}
+	return l.Load1 / float64(l.CPUs)
+}
+
+// MemAvailableRatio 返回可用内存占内存总量的比例，内存总量未知时返回 1
+func (l HostLoad) MemAvailableRatio() float64 {
+	if l.MemTotalBytes == 0 {
+		return 1
+	}
+	return float64(l.MemAvailableBytes) / float64(l.MemTotalBytes)
+}
+
+// ConcurrencyDecision 并发控制器的一次决策
+type ConcurrencyDecision struct {
+	Time            time.Time
+	Load            HostLoad // 决策依据的负载采样
+	Previous        int      // 决策前的并发数
+	Concurrency     int      // 决策后的并发数，已限制在控制器的上下限之间
+	HoldMemoryHeavy bool     // 是否暂缓内存密集型任务
+	Reason          string   // 决策原因
+	Err             error    // 采样或调整失败时的错误，此时并发数保持不变
+}
+
+// ConcurrencyPolicy 并发控制算法，根据当前并发数与主机负载给出新的并发数
+// 返回值中只有 Concurrency、HoldMemoryHeavy 与 Reason 会被使用
+type ConcurrencyPolicy interface {
+	Decide(current int, load HostLoad) ConcurrencyDecision
+}
+
+// ThresholdPolicy 基于阈值的默认并发控制算法
+// 内存不足或内存压力过高时并发数减半并暂缓内存密集型任务；CPU 过载时减少 Step；
+// 负载低于阈值的 3/4 且压力低于阈值的一半时增加 Step；其余情况保持不变。零值字段使用默认值
+type ThresholdPolicy struct {
+	MaxLoadPerCPU     float64 // 每个 CPU 的 1 分钟平均负载上限，默认 1.0
+	MinMemAvailable   float64 // 可用内存占比的下限，默认 0.1
+	MaxCPUPressure    float64 // CPU 压力上限（百分比），默认 40
+	MaxMemoryPressure float64 // 内存压力上限（百分比），默认 10
+	Step              int     // 每次增加或减少的并发数，默认 1
+}
+
+// Decide 实现 ConcurrencyPolicy
+func (p *ThresholdPolicy) Decide(current int, load HostLoad) ConcurrencyDecision {
+	maxLoad := orDefault(p.MaxLoadPerCPU, 1.0)
+	minMem := orDefault(p.MinMemAvailable, 0.1)
+	maxCPUPressure := orDefault(p.MaxCPUPressure, 40)
+	maxMemPressure := orDefault(p.MaxMemoryPressure, 10)
+	step := p.Step
+	if step <= 0 {
+		step = 1
+	}
+
+	switch {
+	case load.MemAvailableRatio() < minMem:
+		return ConcurrencyDecision{Concurrency: current / 2, HoldMemoryHeavy: true, Reason: "low memory"}
+	case load.MemoryPressure > maxMemPressure:
+		return ConcurrencyDecision{Concurrency: current / 2, HoldMemoryHeavy: true, Reason: "memory pressure"}
+	case load.LoadPerCPU() > maxLoad:
+		return ConcurrencyDecision{Concurrency: current - step, Reason: "cpu overloaded"}
+	case load.CPUPressure > maxCPUPressure:
+		return ConcurrencyDecision{Concurrency: current - step, Reason: "cpu pressure"}
+	case load.LoadPerCPU() < maxLoad*3/4 && load.CPUPressure < maxCPUPressure/2 &&
+		load.MemoryPressure < maxMemPressure/2 && load.MemAvailableRatio() >= 2*minMem:
+		return ConcurrencyDecision{Concurrency: current + step, Reason: "headroom"}
+	}
+	return ConcurrencyDecision{Concurrency: current, Reason: "steady"}
+}
+
+// orDefault 返回 v，v 不大于零时返回 def
+func orDefault(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// AdaptiveConcurrency 根据主机负载在 [Min, Max] 内调整执行器并发数（池大小）的控制器
+// 每个采样间隔读取一次负载交给 Policy 决策，通过 GopoolExecutor.Resize 应用新的并发数；
+// 决策要求暂缓内存密集型任务时，声明内存（Resources.MemoryMB，未设置时为 Limits.MemoryBytes
+// 或 AddressSpaceBytes）不少于 MemoryHeavyBytes 的任务留在队列中，其他任务照常执行
+type AdaptiveConcurrency struct {
+	Executor         *GopoolExecutor
+	Min, Max         int                       // 并发数的上下限
+	Interval         time.Duration             // 采样间隔，默认 DefaultAdaptiveInterval
+	Policy           ConcurrencyPolicy         // 控制算法，为 nil 时使用 ThresholdPolicy 的默认值
+	Sampler          func() (HostLoad, error)  // 负载采样函数，为 nil 时使用 SampleHostLoad
+	MemoryHeavyBytes uint64                    // 内存密集型任务的阈值，默认 DefaultMemoryHeavyBytes
+	OnDecision       func(ConcurrencyDecision) // 每次决策后调用（可选）
+
+	mu   sync.Mutex
+	last ConcurrencyDecision
+}
+
+// NewAdaptiveConcurrency 创建并发控制器，调用 Run 后开始调整
+func NewAdaptiveConcurrency(executor *GopoolExecutor, min, max int) *AdaptiveConcurrency {
+	return &AdaptiveConcurrency{Executor: executor, Min: min, Max: max}
+}
+
+// Run 按采样间隔调整并发数，直到 ctx 结束或执行器关闭
+// 退出时解除对内存密集型任务的暂缓，并发数保持最后一次决策的值
+func (c *AdaptiveConcurrency) Run(ctx context.Context) error {
+	if c.Min < 1 || c.Max < c.Min {
+		return fmt.Errorf("invalid concurrency bounds [%d, %d]", c.Min, c.Max)
+	}
+	defer c.Executor.setMemoryHold(0)
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultAdaptiveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if d := c.Step(); d.Err == ErrExecutorClosed {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-c.Executor.Stopped():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Step 立即采样一次并应用决策
+func (c *AdaptiveConcurrency) Step() ConcurrencyDecision {
+	current := c.Executor.PoolSize()
+	d := ConcurrencyDecision{Previous: current, Concurrency: current}
+
+	sample := c.Sampler
+	if sample == nil {
+		sample = SampleHostLoad
+	}
+	load, err := sample()
+	if err != nil {
+		d.Reason, d.Err = "sampling failed", err
+	} else {
+		policy := c.Policy
+		if policy == nil {
+			policy = &ThresholdPolicy{}
+		}
+		decided := policy.Decide(current, load)
+		d.Load, d.Reason, d.HoldMemoryHeavy = load, decided.Reason, decided.HoldMemoryHeavy
+		d.Concurrency = c.clamp(decided.Concurrency)
+		if err := c.Executor.Resize(d.Concurrency); err != nil {
+			d.Concurrency, d.Err = current, err
+		}
+
+		var hold uint64
+		if d.HoldMemoryHeavy {
+			hold = c.MemoryHeavyBytes
+			if hold == 0 {
+				hold = DefaultMemoryHeavyBytes
+			}
+		}
+		c.Executor.setMemoryHold(hold)
+	}
+	d.Time = time.Now()
+
+	c.mu.Lock()
+	c.last = d
+	c.mu.Unlock()
+	if c.OnDecision != nil {
+		c.OnDecision(d)
+	}
+	return d
+}
+
+// Last 返回最近一次决策，尚未决策时返回零值
+func (c *AdaptiveConcurrency) Last() ConcurrencyDecision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// clamp 将并发数限制在 [Min, Max] 内，并发数至少为 1
+func (c *AdaptiveConcurrency) clamp(n int) int {
+	if n > c.Max {
+		n = c.Max
+	}
+	if n < c.Min {
+		n = c.Min
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// memoryBytes 返回任务声明的内存：Resources.MemoryMB，未设置时依次为 Limits.MemoryBytes 与 AddressSpaceBytes
+func (t *Task) memoryBytes() uint64 {
+	if t.Resources != nil && t.Resources.MemoryMB > 0 {
+		return t.Resources.MemoryMB << 20
+	}
+	if t.Limits == nil {
+		return 0
+	}
+	if t.Limits.MemoryBytes > 0 {
+		return t.Limits.MemoryBytes
+	}
+	return t.Limits.AddressSpaceBytes
+}
+
+// setMemoryHold 暂缓声明内存不少于 threshold 的任务，threshold 为零时解除暂缓（内部方法）
+func (e *GopoolExecutor) setMemoryHold(threshold uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.memoryHold != threshold {
+		e.memoryHold = threshold
+		e.notifyLocked()
+	}
+}
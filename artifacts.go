@@ -0,0 +1,301 @@
+package pyExecuter
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxArtifactBytes 单个产物文件默认的最大长度
+const DefaultMaxArtifactBytes = 256 << 20
+
+// DefaultMaxTotalArtifactBytes 一个任务所有产物之和默认的最大长度
+const DefaultMaxTotalArtifactBytes = 1 << 30
+
+// DefaultMaxArtifacts 一个任务默认最多收集的产物数量
+const DefaultMaxArtifacts = 1000
+
+// ErrArtifactTooLarge 产物超过了大小或数量限制
+var ErrArtifactTooLarge = errors.New("artifact too large")
+
+// Artifact 从任务工作目录中收集的一个产物文件
+type Artifact struct {
+	Name     string // 相对工作目录的文件名，使用 "/" 分隔
+	Size     int64  // 文件大小（字节）
+	SHA256   string // 内容的十六进制 SHA-256 摘要
+	Location string // 产物在存储中的位置，可传给 ArtifactStore.Open
+}
+
+// ArtifactLimits 产物收集的限制，0 表示使用默认值，负数表示不限制
+type ArtifactLimits struct {
+	MaxFileBytes  int64 // 单个产物的最大长度，默认 DefaultMaxArtifactBytes
+	MaxTotalBytes int64 // 所有产物之和的最大长度，默认 DefaultMaxTotalArtifactBytes
+	MaxFiles      int   // 最多收集的产物数量，默认 DefaultMaxArtifacts
+}
+
+// ArtifactError 部分产物因超过限制或存储失败而未被收集，与脚本执行失败区分
+type ArtifactError struct {
+	Skipped []string // 未被收集的产物文件名
+	Err     error    // 第一个导致产物被跳过的错误，超过限制时可通过 errors.Is 判断 ErrArtifactTooLarge
+}
+
+// Error 实现 error 接口
+func (e *ArtifactError) Error() string {
+	return fmt.Sprintf("failed to collect %d artifact(s): %v", len(e.Skipped), e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *ArtifactError) Unwrap() error {
+	return e.Err
+}
+
+// ArtifactStore 保存任务产物的存储接口
+type ArtifactStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (string, error) // 保存产物内容，返回其在存储中的位置
+	Open(ctx context.Context, location string) (io.ReadCloser, error) // 读取 Put 返回的位置上的产物
+}
+
+// LocalArtifactStore 将产物保存在本地目录中的 ArtifactStore 实现
+type LocalArtifactStore struct {
+	Root string // 存储根目录，产物保存在 Root/<任务ID>/<文件名>
+}
+
+// NewLocalArtifactStore 创建 LocalArtifactStore 实例
+func NewLocalArtifactStore(root string) *LocalArtifactStore {
+	return &LocalArtifactStore{Root: root}
+}
+
+// Put 将产物写入 Root 下的 key，已存在的同名产物会被覆盖
+func (s *LocalArtifactStore) Put(ctx context.Context, key string, r io.Reader) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	dest := filepath.Join(s.Root, clean)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create artifact directory: %v", err)
+	}
+	// 先写入临时文件再重命名，读取方不会看到写了一半的产物
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create artifact: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write artifact: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write artifact: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("failed to store artifact: %v", err)
+	}
+	return dest, nil
+}
+
+// Open 打开 Put 返回的产物文件，位置必须位于 Root 之下
+func (s *LocalArtifactStore) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	rel, err := filepath.Rel(s.Root, location)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("artifact %s is outside of %s", location, s.Root)
+	}
+	return os.Open(location)
+}
+
+// validateOutputs 校验任务声明的产物匹配模式
+func validateOutputs(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" || strings.HasPrefix(pattern, "/") || filepath.IsAbs(pattern) {
+			return fmt.Errorf("invalid output pattern %q: must be a relative path", pattern)
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if segment == ".." {
+				return fmt.Errorf("invalid output pattern %q: escapes the working directory", pattern)
+			}
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid output pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchOutput 判断相对路径 name 是否匹配 pattern，"**" 匹配任意层目录
+func matchOutput(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments 逐段匹配路径
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// collectArtifacts 将工作目录 dir 中匹配 task.Outputs 的普通文件保存到 store
+// 执行器自身的文件与任务的输入文件不会被收集，符号链接会被忽略
+// 超过 limits 的产物被跳过并在返回的 *ArtifactError 中列出，其余产物照常收集
+func collectArtifacts(ctx context.Context, store ArtifactStore, limits ArtifactLimits, task *Task, dir string) ([]Artifact, error) {
+	if len(task.Outputs) == 0 {
+		return nil, nil
+	}
+	if store == nil {
+		return nil, errors.New("task declares outputs but no artifact store is configured")
+	}
+
+	inputs := make(map[string]bool)
+	if task.Input != nil {
+		for _, file := range task.Input.Files {
+			if name, err := inputFileName(file.Name); err == nil {
+				inputs[filepath.ToSlash(name)] = true
+			}
+		}
+	}
+	prefix := task.ID
+	if prefix == "" {
+		prefix = randomID()
+	}
+
+	maxFile := inputLimit(limits.MaxFileBytes, DefaultMaxArtifactBytes)
+	maxTotal := inputLimit(limits.MaxTotalBytes, DefaultMaxTotalArtifactBytes)
+	maxFiles := limits.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = DefaultMaxArtifacts
+	}
+
+	var artifacts []Artifact
+	var artifactErr *ArtifactError
+	skip := func(name string, err error) {
+		if artifactErr == nil {
+			artifactErr = &ArtifactError{Err: err}
+		}
+		artifactErr.Skipped = append(artifactErr.Skipped, name)
+	}
+	total := int64(0)
+
+	walkErr := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		name := filepath.ToSlash(rel)
+		if name == "." {
+			return nil
+		}
+		if !strings.Contains(name, "/") && reservedFileName(name) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || inputs[name] || !matchAny(task.Outputs, name) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			skip(name, err)
+			return nil
+		}
+		switch {
+		case maxFiles > 0 && len(artifacts) >= maxFiles:
+			skip(name, fmt.Errorf("%w: more than %d artifacts", ErrArtifactTooLarge, maxFiles))
+			return nil
+		case info.Size() > maxFile:
+			skip(name, fmt.Errorf("%w: %s is %d bytes, limit is %d bytes", ErrArtifactTooLarge, name, info.Size(), maxFile))
+			return nil
+		case total+info.Size() > maxTotal:
+			skip(name, fmt.Errorf("%w: total artifacts exceed %d bytes", ErrArtifactTooLarge, maxTotal))
+			return nil
+		}
+
+		artifact, err := storeArtifact(ctx, store, prefix+"/"+name, p, name)
+		if err != nil {
+			skip(name, err)
+			return nil
+		}
+		total += artifact.Size
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	if walkErr != nil {
+		return artifacts, fmt.Errorf("failed to collect artifacts: %w", walkErr)
+	}
+	if artifactErr != nil {
+		return artifacts, artifactErr
+	}
+	return artifacts, nil
+}
+
+// storeArtifact 将文件 p 保存到 store，同时计算大小与摘要
+func storeArtifact(ctx context.Context, store ArtifactStore, key, p, name string) (Artifact, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	location, err := store.Put(ctx, key, io.TeeReader(f, io.MultiWriter(hash, counter)))
+	if err != nil {
+		return Artifact{}, err
+	}
+	return Artifact{Name: name, Size: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil)), Location: location}, nil
+}
+
+// matchAny 判断 name 是否匹配任意一个模式
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchOutput(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter 统计写入字节数的 io.Writer
+type countingWriter struct {
+	n int64
+}
+
+// Write 实现 io.Writer 接口
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// randomID 返回随机的十六进制标识，用于没有ID的任务
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
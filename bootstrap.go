@@ -0,0 +1,44 @@
+package pyExecuter
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// bootstrapFileName 启动脚本在临时目录中的文件名
+const bootstrapFileName = "_pyexecuter_bootstrap.py"
+
+// helperFileName 辅助模块在临时目录中的文件名，脚本通过 "import pyexecuter" 使用
+const helperFileName = "pyexecuter.py"
+
+// bootstrapScript 启动脚本的内容，它在运行用户脚本前完成资源限制等准备工作
+//
+//go:embed bootstrap.py
+var bootstrapScript []byte
+
+// helperScript 注入给脚本的辅助模块，提供结果通道等功能
+//
+//go:embed pyexecuter.py
+var helperScript []byte
+
+// writeBootstrap 将启动脚本与辅助模块写入 dir，返回启动脚本的路径
+func writeBootstrap(dir string) (string, error) {
+	if err := writeHelper(dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, bootstrapFileName)
+	if err := os.WriteFile(path, bootstrapScript, 0600); err != nil {
+		return "", fmt.Errorf("failed to write bootstrap script: %v", err)
+	}
+	return path, nil
+}
+
+// writeHelper 将辅助模块写入 dir，dir 需位于脚本的模块搜索路径中
+func writeHelper(dir string) error {
+	if err := os.WriteFile(filepath.Join(dir, helperFileName), helperScript, 0644); err != nil {
+		return fmt.Errorf("failed to write helper module: %v", err)
+	}
+	return nil
+}
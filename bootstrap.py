@@ -0,0 +1,131 @@
+"""pyExecuter 启动脚本：准备运行环境后执行用户脚本。
+
+用法: python bootstrap.py target [args...]
+
+target 的含义由 PYEXECUTER_SOURCE 决定：script 与 file 为脚本文件，archive 为 .pyz 归档，
+entry_point 为 "module" 或 "module:function" 形式的入口点。
+PYEXECUTER_PYTHONPATH 中的路径会加入模块搜索路径。
+"""
+import os
+import sys
+
+
+def _apply_rlimits():
+    """按 PYEXECUTER_RLIMITS 中的 JSON 配置设置资源限制。"""
+    spec = os.environ.pop("PYEXECUTER_RLIMITS", "")
+    if not spec:
+        return
+    import json
+    import resource
+
+    for name, (soft, hard) in json.loads(spec).items():
+        resource.setrlimit(getattr(resource, name), (soft, hard))
+
+
+def _install_seccomp_reporter():
+    """被 seccomp 规则拒绝时报告系统调用号，随后以 SIGSYS 终止进程。
+
+    仅在 PYEXECUTER_SECCOMP_TRAP 存在时安装。处理器需要 siginfo 中的
+    si_syscall，Python 的 signal 模块无法提供，因此通过 ctypes 调用 sigaction。
+    """
+    if not os.environ.pop("PYEXECUTER_SECCOMP_TRAP", ""):
+        return
+    import ctypes
+
+    global _sigsys_handler
+    libc = ctypes.CDLL(None, use_errno=True)
+    libc.signal.restype = ctypes.c_void_p
+    libc.signal.argtypes = [ctypes.c_int, ctypes.c_void_p]
+    handler_type = ctypes.CFUNCTYPE(None, ctypes.c_int, ctypes.c_void_p, ctypes.c_void_p)
+
+    def handler(signum, info, context):
+        try:
+            # siginfo_t 中 si_syscall 位于偏移 24 处（64 位 Linux）
+            nr = ctypes.c_int.from_address(info + 24).value
+            os.write(2, b"pyexecuter: seccomp violation: syscall=%d\n" % nr)
+        finally:
+            if os.getpid() == 1:
+                # 作为 PID 命名空间的 init 进程时，默认处理的信号会被忽略，只能以约定的退出码退出
+                os._exit(128 + signum)
+            # 恢复默认处理后重新发送信号，处理器返回后进程即被终止
+            libc.signal(signum, None)
+            getattr(libc, "raise")(signum)
+
+    class sigaction(ctypes.Structure):
+        _fields_ = [
+            ("sa_sigaction", handler_type),
+            ("sa_mask", ctypes.c_ulong * 16),
+            ("sa_flags", ctypes.c_int),
+            ("sa_restorer", ctypes.c_void_p),
+        ]
+
+    _sigsys_handler = handler_type(handler)
+    action = sigaction(sa_sigaction=_sigsys_handler, sa_flags=4)  # SA_SIGINFO
+    if libc.sigaction(31, ctypes.byref(action), None) != 0:  # SIGSYS
+        raise OSError(ctypes.get_errno(), "failed to install SIGSYS handler")
+
+
+def _run_entry_point(entry):
+    """像 python -m 一样运行模块，或导入模块后调用函数并以其返回值退出。"""
+    import runpy
+
+    module, _, function = entry.partition(":")
+    if not function:
+        runpy.run_module(module, run_name="__main__", alter_sys=True)
+        return
+
+    import importlib
+
+    target = importlib.import_module(module)
+    for name in function.split("."):
+        target = getattr(target, name)
+    sys.exit(target())
+
+
+def main():
+    _apply_rlimits()
+    _install_seccomp_reporter()
+
+    kind = os.environ.pop("PYEXECUTER_SOURCE", "script")
+    python_path = [p for p in os.environ.pop("PYEXECUTER_PYTHONPATH", "").split(os.pathsep) if p]
+    target = sys.argv[1]
+    error_path = os.environ.pop("PYEXECUTER_ERROR_PATH", "")
+    sys.argv = sys.argv[1:]
+    if kind == "entry_point":
+        sys.path[0] = os.getcwd()
+    elif kind == "archive":
+        sys.path[0] = target
+    else:
+        sys.path[0] = os.path.dirname(os.path.abspath(target))
+    sys.path[1:1] = python_path
+    # 辅助模块与启动脚本位于同一目录，脚本不在该目录时也要能导入
+    helper_dir = os.path.dirname(os.path.abspath(__file__))
+    if helper_dir not in sys.path:
+        sys.path.append(helper_dir)
+
+    import runpy
+
+    try:
+        if kind == "entry_point":
+            _run_entry_point(target)
+        else:
+            runpy.run_path(target, run_name="__main__")
+    except SystemExit:
+        raise
+    except BaseException as e:
+        # 记录结构化的异常信息，并像解释器一样打印（不含启动脚本自身的帧）后以 1 退出
+        import traceback
+
+        import pyexecuter
+
+        tb = pyexecuter._strip_traceback(e.__traceback__, target if kind == "script" else None)
+        if error_path:
+            pyexecuter._report_exception(e, tb, error_path)
+        if isinstance(e, KeyboardInterrupt):
+            raise
+        traceback.print_exception(type(e), e, tb)
+        sys.exit(1)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,150 @@
+package pyExecuter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// cgroupMount cgroup v2 的挂载点
+const cgroupMount = "/sys/fs/cgroup"
+
+// cgroupSeq 用于生成唯一的 cgroup 名称
+var cgroupSeq uint64
+
+// taskCgroup 为单个任务创建的临时 cgroup
+type taskCgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// newTaskCgroup 在 parent 下为任务创建临时 cgroup 并写入限制，限制中没有需要 cgroup 的项时返回 nil
+// parent 为空时只能使用根 cgroup：cgroup v2 中非根 cgroup 不能既包含进程又向子 cgroup 分配控制器，
+// 当前进程所在的 cgroup 无法容纳任务的 cgroup，此时需要通过 CgroupParent 指定委派给执行器的 cgroup。
+// cgroup v2 不可用、不可写或无法写入限制时返回错误，任务不会在没有限制的情况下运行
+func newTaskCgroup(parent string, limits *ResourceLimits) (*taskCgroup, error) {
+	if limits == nil || (limits.MemoryBytes == 0 && limits.Processes == 0) {
+		return nil, nil
+	}
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("cgroup v2 is not available at %s", cgroupMount)
+	}
+	if parent == "" {
+		current := currentCgroup()
+		if current != cgroupMount {
+			return nil, fmt.Errorf("CgroupParent is required: the current cgroup %s contains processes and cannot hold task cgroups", current)
+		}
+		parent = current
+	}
+
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+memory +pids"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to enable memory and pids controllers in %s: %v", parent, err)
+	}
+
+	name := fmt.Sprintf("pyexecuter-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupSeq, 1))
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cgroup: %v", err)
+	}
+	cg := &taskCgroup{dir: dir}
+
+	if limits.MemoryBytes > 0 {
+		if err := cg.write("memory.max", strconv.FormatUint(limits.MemoryBytes, 10)); err != nil {
+			cg.remove()
+			return nil, fmt.Errorf("failed to set memory.max: %v", err)
+		}
+		cg.write("memory.swap.max", "0")
+	}
+	if limits.Processes > 0 {
+		if err := cg.write("pids.max", strconv.FormatUint(limits.Processes, 10)); err != nil {
+			cg.remove()
+			return nil, fmt.Errorf("failed to set pids.max: %v", err)
+		}
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		cg.remove()
+		return nil, fmt.Errorf("failed to open cgroup: %v", err)
+	}
+	cg.fd = fd
+	return cg, nil
+}
+
+// attach 让命令启动时直接进入该 cgroup
+func (c *taskCgroup) attach(cmd *exec.Cmd) {
+	if c == nil {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+}
+
+// events 读取 cgroup 中记录的资源限制事件
+func (c *taskCgroup) events() cgroupEvents {
+	var events cgroupEvents
+	if c == nil {
+		return events
+	}
+	events.OOMKills = readCgroupCounter(filepath.Join(c.dir, "memory.events"), "oom_kill")
+	events.PidsMax = readCgroupCounter(filepath.Join(c.dir, "pids.events"), "max")
+	return events
+}
+
+// remove 删除 cgroup，调用前其中的进程应已全部退出
+func (c *taskCgroup) remove() {
+	if c == nil {
+		return
+	}
+	if c.fd != nil {
+		c.fd.Close()
+	}
+	os.Remove(c.dir)
+}
+
+// write 写入 cgroup 的控制文件
+func (c *taskCgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(c.dir, file), []byte(value), 0644)
+}
+
+// currentCgroup 返回当前进程所在的 cgroup v2 目录
+func currentCgroup() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return filepath.Join(cgroupMount, strings.TrimPrefix(line, "0::"))
+		}
+	}
+	return ""
+}
+
+// readCgroupCounter 读取 "key value" 格式的 cgroup 事件文件中的计数
+func readCgroupCounter(path, key string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package pyExecuter
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// taskCgroup 当前平台不支持 cgroup
+type taskCgroup struct{}
+
+// newTaskCgroup 当前平台不支持 cgroup，限制中有需要 cgroup 的项时返回错误
+func newTaskCgroup(parent string, limits *ResourceLimits) (*taskCgroup, error) {
+	if limits == nil || (limits.MemoryBytes == 0 && limits.Processes == 0) {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+}
+
+// attach 当前平台不支持 cgroup
+func (c *taskCgroup) attach(cmd *exec.Cmd) {}
+
+// events 当前平台不支持 cgroup
+func (c *taskCgroup) events() cgroupEvents {
+	return cgroupEvents{}
+}
+
+// remove 当前平台不支持 cgroup
+func (c *taskCgroup) remove() {}
@@ -0,0 +1,152 @@
+package pyExecuter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DependencySpec 描述任务脚本所需的Python依赖
+type DependencySpec struct {
+	Requirements    []string // 依赖列表，例如 "requests==2.31.0"
+	RequirementsTxt string   // 内联的 requirements.txt 内容，原样交给 pip
+}
+
+// PipOptions 安装依赖时使用的 pip 配置，默认仅从本地目录离线安装
+type PipOptions struct {
+	Wheelhouse string   // 本地 wheel 目录，作为 --find-links 使用
+	FindLinks  []string // 额外的 find-links 目录
+	AllowIndex bool     // 是否允许访问包索引，为 false 时使用 --no-index
+}
+
+// cacheKey 返回影响安装结果的选项描述，用于区分虚拟环境的缓存
+func (o PipOptions) cacheKey() string {
+	return fmt.Sprintf("wheelhouse=%s\nfind-links=%s\nallow-index=%t", o.Wheelhouse, strings.Join(o.FindLinks, "\n"), o.AllowIndex)
+}
+
+// DependencyError 依赖安装失败的错误，与脚本执行失败区分
+type DependencyError struct {
+	Requirements string // 规范化后的依赖描述
+	Output       string // pip 的输出
+	Err          error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("failed to install dependencies: %v", e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// normalized 返回规范化的依赖描述：合并以 "\" 续行的行，去除注释与空行、去重并排序
+// 相同的依赖集合总是得到相同的描述，从而命中同一个缓存环境；该描述只用作缓存键，不会交给 pip
+func (d *DependencySpec) normalized() string {
+	if d == nil {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var lines []string
+	add := func(line string) {
+		line = stripComment(line)
+		if line == "" || seen[line] {
+			return
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	for _, req := range d.Requirements {
+		add(req)
+	}
+	for _, line := range joinContinuations(d.RequirementsTxt) {
+		add(line)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// requirementsFile 返回交给 pip 的 requirements 文件内容：Requirements 每项一行，其后是原样的 RequirementsTxt
+// 续行、选项行（--index-url、-c、--hash 等）与依赖的相对位置保持不变
+func (d *DependencySpec) requirementsFile() string {
+	var b strings.Builder
+	for _, req := range d.Requirements {
+		b.WriteString(req + "\n")
+	}
+	b.WriteString(d.RequirementsTxt)
+	return b.String()
+}
+
+// joinContinuations 按行拆分 requirements.txt，以 "\" 结尾的行与下一行合并
+func joinContinuations(text string) []string {
+	var lines []string
+	var current strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(trimmed, "\\") && !strings.HasPrefix(strings.TrimSpace(trimmed), "#") {
+			current.WriteString(strings.TrimSuffix(trimmed, "\\") + " ")
+			continue
+		}
+		current.WriteString(line)
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+	return lines
+}
+
+// stripComment 去除行首或空白之后以 "#" 开始的注释，保留 URL 中的 #egg= 与 #sha256= 等片段
+func stripComment(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+	for i := 1; i < len(line); i++ {
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return line
+}
+
+// installDependencies 使用虚拟环境中的 pip 安装依赖
+func installDependencies(ctx context.Context, envDir string, deps *DependencySpec, opts PipOptions) error {
+	requirements := deps.normalized()
+	if requirements == "" {
+		return nil
+	}
+
+	reqFile, err := os.CreateTemp("", "requirements_*.txt")
+	if err != nil {
+		return &DependencyError{Requirements: requirements, Err: fmt.Errorf("failed to write requirements file: %v", err)}
+	}
+	defer os.Remove(reqFile.Name())
+	_, err = reqFile.WriteString(deps.requirementsFile() + "\n")
+	reqFile.Close()
+	if err != nil {
+		return &DependencyError{Requirements: requirements, Err: fmt.Errorf("failed to write requirements file: %v", err)}
+	}
+
+	args := []string{"-m", "pip", "install", "--disable-pip-version-check", "--no-input"}
+	if !opts.AllowIndex {
+		args = append(args, "--no-index")
+	}
+	if opts.Wheelhouse != "" {
+		args = append(args, "--find-links", opts.Wheelhouse)
+	}
+	for _, link := range opts.FindLinks {
+		args = append(args, "--find-links", link)
+	}
+	args = append(args, "-r", reqFile.Name())
+
+	cmd := exec.CommandContext(ctx, filepath.Join(envDir, "bin", "python"), args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &DependencyError{Requirements: requirements, Output: string(output), Err: err}
+	}
+	return nil
+}
@@ -38,10 +38,10 @@ func NewBasicErrorHandler(maxRetry int, retryInterval time.Duration, queue *Task
 // CaptureError 处理任务执行中的异常
 func (h *BasicErrorHandler) CaptureError(taskID string, err error) error {
 	if h.retryCount[taskID] >= h.MaxRetryCount {
-		return fmt.Errorf("task %s exceeded max retry count with error: %v", taskID, err)
+		return fmt.Errorf("task %s exceeded max retry count with error: %w", taskID, err)
 	}
 
-	fmt.Printf("Task %s encountered an error: %v. Retrying...\n", taskID, err)
+	fmt.Printf("Task %s encountered an error: %s. Retrying...\n", taskID, RedactTaskSecrets(taskID, err.Error()))
 	time.Sleep(h.RetryInterval)
 	return h.RetryTask(taskID)
 }
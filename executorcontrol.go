@@ -0,0 +1,143 @@
+package pyExecuter
+
+import (
+	"fmt"
+	"time"
+
+	gopool "github.com/devchat-ai/gopool"
+)
+
+// ExecutorEventType 执行器事件的类型
+type ExecutorEventType string
+
+const (
+	EventStarted      ExecutorEventType = "started"       // 开始分发任务
+	EventPaused       ExecutorEventType = "paused"        // 暂停分发
+	EventResumed      ExecutorEventType = "resumed"       // 恢复分发
+	EventResized      ExecutorEventType = "resized"       // 池大小改变
+	EventShuttingDown ExecutorEventType = "shutting_down" // 开始关闭
+	EventStopped      ExecutorEventType = "stopped"       // 关闭完成
+)
+
+// ExecutorEvent 执行器状态变化的事件
+type ExecutorEvent struct {
+	Type             ExecutorEventType
+	Time             time.Time
+	PoolSize         int // 事件发生后的池大小
+	PreviousPoolSize int // EventResized 事件中调整前的池大小
+}
+
+// newGoPool 创建固定 size 个 worker 的 GoPool
+// 出队的任务数已由执行器限制，worker 数不需要 gopool 按秒扩缩，也避免了扩容带来的延迟
+func newGoPool(size int) gopool.GoPool {
+	return gopool.NewGoPool(size)
+}
+
+// Pause 暂停分发任务：正在执行的任务继续执行直到结束，队列照常接受新任务
+func (e *GopoolExecutor) Pause() error {
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return ErrExecutorClosed
+	}
+	if e.paused {
+		e.mu.Unlock()
+		return nil
+	}
+	e.paused = true
+	size := e.size
+	e.mu.Unlock()
+
+	e.emit(ExecutorEvent{Type: EventPaused, PoolSize: size})
+	return nil
+}
+
+// Resume 恢复分发任务
+func (e *GopoolExecutor) Resume() error {
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return ErrExecutorClosed
+	}
+	if !e.paused {
+		e.mu.Unlock()
+		return nil
+	}
+	e.paused = false
+	e.notifyLocked()
+	size := e.size
+	e.mu.Unlock()
+
+	e.emit(ExecutorEvent{Type: EventResumed, PoolSize: size})
+	return nil
+}
+
+// Resize 将池大小（最大并发任务数）调整为 n
+// 新任务在新的 GoPool 中执行；旧 GoPool 中正在执行的任务不受影响，全部结束后释放其 worker。
+// 缩小时超出的任务执行完毕后才会分发新任务
+func (e *GopoolExecutor) Resize(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid pool size %d", n)
+	}
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return ErrExecutorClosed
+	}
+	previous := e.size
+	if n == previous {
+		e.mu.Unlock()
+		return nil
+	}
+	old := e.pool
+	e.pool = newGoPool(n)
+	e.size = n
+	e.notifyLocked()
+	e.mu.Unlock()
+
+	// 提交任务时持有 e.mu，此后不会再向旧池提交
+	go old.Release()
+	e.emit(ExecutorEvent{Type: EventResized, PoolSize: n, PreviousPoolSize: previous})
+	return nil
+}
+
+// PoolSize 返回当前的池大小
+func (e *GopoolExecutor) PoolSize() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.size
+}
+
+// Paused 返回执行器是否处于暂停状态
+func (e *GopoolExecutor) Paused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+// stateLocked 返回执行器当前状态的名称，调用方须持有 e.mu（内部方法）
+func (e *GopoolExecutor) stateLocked() string {
+	select {
+	case <-e.stopped:
+		return "stopped"
+	default:
+	}
+	switch {
+	case e.closing:
+		return "stopping"
+	case e.paused:
+		return "paused"
+	case e.dispatched == nil:
+		return "created"
+	}
+	return "running"
+}
+
+// emit 将事件传给 OnEvent（内部方法）
+func (e *GopoolExecutor) emit(event ExecutorEvent) {
+	if e.OnEvent == nil {
+		return
+	}
+	event.Time = time.Now()
+	e.OnEvent(event)
+}
@@ -4,4 +4,9 @@ go 1.20
 
 require github.com/devchat-ai/gopool v0.6.2
 
-require github.com/stretchr/testify v1.9.0 // indirect
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/testify v1.9.0
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -2,6 +2,7 @@ package pyExecuter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -13,94 +14,552 @@ import (
 type Task struct {
 	ID           string              // 任务的唯一ID
 	Script       string              // Python脚本代码（字符串形式）
+	ScriptPath   string              // Python脚本文件的路径，与 Script 二选一，脚本所在目录加入模块搜索路径
+	Archive      string              // 以 zipapp 方式运行的 .pyz 归档路径，归档中须包含 __main__.py
+	EntryPoint   string              // 入口点，"module" 按 python -m 运行模块，"module:function" 调用函数并以返回值作为退出码
+	PythonPath   []string            // 加入模块搜索路径的目录或 zip 文件，可与任意一种脚本来源组合（可选）
 	Args         []string            // 脚本执行的参数
+	Env          map[string]string   // 任务的环境变量，覆盖继承的同名变量（可选）
+	SecretEnv    map[string]string   `json:"-"` // 敏感的环境变量，值至少 MinSecretLength 个字符，任务开始执行后库写入的日志会将其替换为 RedactedValue，直到调用 ReleaseTaskSecrets，不会被 SaveTasks 保存（可选）
+	EnvInherit   EnvInheritance      // 从当前进程继承环境变量的策略，默认只继承允许列表中的变量
+	EnvAllowlist []string            // InheritAllowlist 策略下允许继承的变量，为 nil 时使用 DefaultEnvAllowlist
+	WorkDir      string              // 脚本的工作目录，为空时使用执行器的默认目录（声明了 Outputs 时为临时工作目录）
+	Input        *TaskInput          // 脚本的输入数据：标准输入、JSON 负载与输入文件（可选）
+	Outputs      []string            // 需要收集的产物文件的匹配模式，相对工作目录，"**" 匹配任意层目录（可选）
+	Dependencies *DependencySpec     // 脚本所需的Python依赖（可选）
+	Interpreter  InterpreterSelector // 执行脚本的Python解释器（可选）
+	Limits       *ResourceLimits     // 任务的资源限制（可选）
+	Resources    *Resources          // 任务声明的资源需求，执行器设置了 Capacity 时只在剩余容量足够时出队（可选）
+	OutputLimits *OutputLimits       // 标准输出与标准错误的上限及超限策略，为 nil 时使用执行器的默认值（可选）
 	Priority     int                 // 任务的优先级（可选）
 	Timeout      time.Duration       // 任务超时时间
 	RetryCount   int                 // 重试次数
-	OnCompletion func(result Result) // 任务完成后的回调函数
+	OnCompletion func(result Result) `json:"-"` // 任务完成后的回调函数
+
+	bypassed int // 因资源不足被靠后的任务越过的次数，由执行器的 mu 保护
 }
 
 // Result 描述任务执行的结果
 type Result struct {
-	TaskID    string    // 对应任务的ID
-	Output    string    // 执行的输出结果
-	Error     error     // 执行过程中产生的错误
-	StartTime time.Time // 任务开始时间
-	EndTime   time.Time // 任务结束时间
+	TaskID    string     // 对应任务的ID
+	Output    string     // 执行的输出结果（标准输出与标准错误的拼接）
+	Error     error      // 执行过程中产生的错误
+	StartTime time.Time  // 任务开始时间
+	EndTime   time.Time  // 任务结束时间
+	Status    TaskStatus // 任务结束时的状态：TaskSucceeded、TaskFailed 或 TaskCancelled
+
+	ExecutionResult // 结构化的执行结果（标准输出、标准错误、退出码等）
 }
 
 // GopoolExecutor GoPool 的任务执行管理器
 type GopoolExecutor struct {
-	pool  gopool.GoPool // 使用 devchat-ai/gopool 提供的池
-	Queue *TaskQueue    // 任务队列
-	mu    sync.Mutex    // 保护任务调度的锁
+	pool   gopool.GoPool // 使用 devchat-ai/gopool 提供的池
+	Queue  *TaskQueue    // 任务队列
+	Output *OutputStream // 任务输出流，可订阅运行中任务的实时输出
+	Venvs  *VenvManager  // 任务间共享的虚拟环境管理器
+	// Executor 执行任务所用的执行器（例如 SandboxExecutor），为 nil 时按下列配置创建 SecurePythonExecutor
+	Executor PythonExecutor
+	Pip      PipOptions // 安装任务依赖时使用的 pip 配置
+
+	Interpreter  InterpreterSelector  // 任务未指定解释器时使用的默认选择条件
+	Interpreters *InterpreterRegistry // 解释器注册表，用于解析任务的解释器
+
+	KillGracePeriod time.Duration   // 超时或取消时，SIGTERM 与 SIGKILL 之间的宽限期
+	CgroupParent    string          // 创建任务 cgroup 的父目录（不包含进程、委派给执行器的 cgroup），为空时只能使用根 cgroup
+	Seccomp         *SeccompProfile // 执行Python前安装的 seccomp 过滤规则，为 nil 时不启用
+	InputLimits     InputLimits     // 任务输入的大小限制，提交任务时即按该限制校验
+	Artifacts       ArtifactStore   // 保存任务产物的存储，任务声明了 Outputs 时必须设置
+	ArtifactLimits  ArtifactLimits  // 产物收集的限制
+	OutputLimits    *OutputLimits   // 任务未设置 OutputLimits 时使用的输出上限
+	Monitor         TaskMonitor     // 接收任务状态的监控器（可选）
+	Recovery        Recovery        // 保存任务状态的恢复存储，实现了 PendingTaskStore 时 Shutdown 会保存未完成的任务（可选）
+	DrainOnShutdown bool            // Shutdown 时是否先执行完队列中的任务，否则立即停止分发
+	Capacity        *Resources      // 可分配给任务的资源容量，为 nil 时不按资源准入；需求超过容量的任务提交时被拒绝
+	MaxBypass       int             // 资源不足的任务最多被靠后的任务越过的次数，默认 DefaultMaxBypass
+	// OnEvent 执行器状态变化（启动、暂停、恢复、调整池大小、关闭）时在触发变化的 goroutine 中调用，不应阻塞
+	OnEvent func(ExecutorEvent)
+	mu      sync.Mutex // 保护任务调度的锁
+
+	handles  map[*Task]*TaskHandle // 通过 Submit 提交、尚未结束的任务
+	handleMu sync.Mutex
+	running  map[*Task]context.CancelCauseFunc // 已出队、尚未结束的任务的取消函数，由 mu 保护
+
+	// 以下字段由 mu 保护
+	size         int                // 池大小，即已出队、尚未结束的任务数上限
+	active       int                // 已出队、尚未结束的任务数
+	paused       bool               // 是否暂停分发
+	memoryHold   uint64             // 非零时暂缓声明内存不少于该值的任务，由 AdaptiveConcurrency 设置
+	reserved     Resources          // 已出队、尚未结束的任务预留的资源
+	closing      bool               // 已开始关闭，不再接受新任务
+	changed      chan struct{}      // 有任务结束时关闭，用于等待执行中的任务
+	stopDispatch context.CancelFunc // 停止分发任务
+	dispatched   chan struct{}      // 分发协程退出后关闭
+	interrupted  []*Task            // 关闭时被终止的任务
+	finished     map[TaskStatus]int // 各最终状态的任务数
+
+	shutdownOnce sync.Once
+	stopped      chan struct{} // 关闭完成后关闭
+	report       *ShutdownReport
+	shutdownErr  error
 }
 
 // NewGopoolExecutor 创建一个GopoolExecutor实例
 func NewGopoolExecutor(poolSize int, queue *TaskQueue) *GopoolExecutor {
-	pool := gopool.NewGoPool(poolSize, gopool.WithMinWorkers(poolSize/2))
 	return &GopoolExecutor{
-		pool:  pool,
-		Queue: queue,
+		pool:    newGoPool(poolSize),
+		size:    poolSize,
+		stopped: make(chan struct{}),
+		Queue:   queue,
+		Output:  NewOutputStream(StreamLines, DefaultStreamBuffer),
+		Venvs:   NewVenvManager(""),
+
+		Interpreters: NewInterpreterRegistry(),
 	}
 }
 
 // Start 启动GopoolExecutor，持续从任务队列获取任务并执行
+// ctx 结束时执行器立即关闭，效果与以已结束的 ctx 调用 Shutdown 相同
 func (e *GopoolExecutor) Start(ctx context.Context) error {
+	e.mu.Lock()
+	if e.closing {
+		e.mu.Unlock()
+		return ErrExecutorClosed
+	}
+	if e.dispatched != nil {
+		e.mu.Unlock()
+		return errors.New("executor is already started")
+	}
+	dispatchCtx, stop := context.WithCancel(context.Background())
+	dispatched := make(chan struct{})
+	e.stopDispatch, e.dispatched = stop, dispatched
+	size := e.size
+	e.mu.Unlock()
+
 	// 利用 GoPool 并行执行任务，从任务队列获取任务并提交
 	go func() {
-		for {
+		defer close(dispatched)
+		for e.dispatch(dispatchCtx) == nil {
+		}
+	}()
+	e.emit(ExecutorEvent{Type: EventStarted, PoolSize: size})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			expired, cancel := context.WithCancel(context.Background())
+			cancel()
+			e.Shutdown(expired)
+		case <-e.stopped:
+		}
+	}()
+	return nil
+}
+
+// dispatch 等待空闲的执行名额，取出一个任务交给 GoPool 执行，直到 ctx 结束（内部方法）
+// 暂停或池已满时不出队，任务留在队列中，仍可被取消且保持优先级顺序；暂不能执行的任务（见 takeLocked）被跳过。
+// 出队、登记取消函数与提交在同一把锁内完成，任务任何时候都能被 CancelTask 在队列或执行中找到
+func (e *GopoolExecutor) dispatch(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		if e.paused || e.active >= e.size {
+			changed := e.changedLocked()
+			e.mu.Unlock()
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		// 先取得加入通知再查找任务，查找之后加入的任务不会被遗漏
+		added := e.Queue.added()
+		task, reservation := e.takeLocked()
+		if task == nil {
+			// 队列为空或其中的任务暂不能执行，等待新任务或执行器状态变化
+			changed := e.changedLocked()
+			e.mu.Unlock()
 			select {
+			case <-added:
+			case <-changed:
 			case <-ctx.Done():
-				e.pool.Release()
-				return
-			default:
-				e.mu.Lock()
-				task, err := e.Queue.GetTask() // 获取任务
-				e.mu.Unlock()
-				if err == nil && task != nil {
-					e.pool.AddTask(func() (interface{}, error) {
-						result := e.ExecuteTask(task)
-						if result.Error != nil {
-							if task.RetryCount > 0 {
-								task.RetryCount--
-								e.Queue.AddTask(task) // 任务失败，重新添加到队列
-							} else {
-								// 记录失败日志
-								fmt.Printf("Task %s failed after retries: %v\n", task.ID, result.Error)
-							}
-						}
-						return result, result.Error
-					})
-				} else {
-					// 如果队列为空，等待一段时间再尝试
-					time.Sleep(100 * time.Millisecond)
-				}
+				return ctx.Err()
 			}
+			continue
+		}
+		e.active++
+		taskCtx := e.begin(task)
+		e.pool.AddTask(func() (interface{}, error) {
+			defer e.release(reservation)
+			result := e.runTask(taskCtx, task)
+			return result, result.Error
+		})
+		e.mu.Unlock()
+		return nil
+	}
+}
+
+// release 归还任务占用的执行名额与预留的资源（内部方法）
+func (e *GopoolExecutor) release(reservation *Resources) {
+	e.mu.Lock()
+	e.active--
+	e.reserved.sub(reservation)
+	e.notifyLocked()
+	e.mu.Unlock()
+}
+
+// begin 登记出队任务的取消函数并返回执行任务所用的 ctx，调用方须持有 e.mu（内部方法）
+func (e *GopoolExecutor) begin(task *Task) context.Context {
+	parent := context.Background()
+	if h := e.handle(task); h != nil {
+		parent = h.ctx
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	if e.running == nil {
+		e.running = make(map[*Task]context.CancelCauseFunc)
+	}
+	e.running[task] = cancel
+	return ctx
+}
+
+// runTask 执行从队列取出的任务，失败时按重试次数重新排队（内部方法）
+// 被取消的任务以 TaskCancelled 状态结束，不会重试
+func (e *GopoolExecutor) runTask(ctx context.Context, task *Task) Result {
+	// 登记任务的敏感环境变量，报告结果后保留到调用 ReleaseTaskSecrets
+	defer secrets.register(task)()
+	h := e.handle(task)
+	if h != nil && !h.start() {
+		// 任务在出队前已经结束
+		e.mu.Lock()
+		e.running[task](nil)
+		delete(e.running, task)
+		e.notifyLocked()
+		e.mu.Unlock()
+		e.forget(task)
+		res, _ := h.Result()
+		return res
+	}
+
+	e.track(task.ID, TaskRunning)
+	result := e.executeTask(ctx, task)
+
+	// 重新排队与注销取消函数在同一把锁内完成，CancelTask 总能在队列或执行中找到任务
+	e.mu.Lock()
+	if result.Status == TaskCancelled && errors.Is(context.Cause(ctx), ErrExecutorClosed) {
+		e.interrupted = append(e.interrupted, task)
+	}
+	e.running[task](nil)
+	delete(e.running, task)
+	e.notifyLocked()
+	retried := false
+	if result.Error != nil && result.Status != TaskCancelled && task.RetryCount > 0 {
+		task.RetryCount--
+		// 任务失败，重新添加到队列
+		if err := e.Queue.AddTask(task); err == nil {
+			retried = true
+			if h != nil {
+				h.requeue()
+			}
+		}
+	}
+	e.mu.Unlock()
+
+	if retried {
+		e.track(task.ID, TaskQueued)
+		return result
+	}
+	if result.Status == TaskFailed {
+		// 记录失败日志
+		fmt.Printf("Task %s failed after retries: %s\n", task.ID, RedactTaskSecrets(task.ID, result.Error.Error()))
+	}
+	e.track(task.ID, result.Status)
+	if h != nil {
+		e.forget(task)
+		h.finish(result)
+	}
+	return result
+}
+
+// CancelTask 取消指定ID的任务：仍在队列中的任务被移出队列，正在执行的任务的进程组被终止
+// 被取消的任务以 TaskCancelled 状态结束，通过 OnCompletion、Monitor 与 Recovery 报告，并且不会重试
+func (e *GopoolExecutor) CancelTask(taskID string) error {
+	e.mu.Lock()
+	var removed []*Task
+	for {
+		task, err := e.Queue.RemoveTask(taskID)
+		if err != nil {
+			break
+		}
+		removed = append(removed, task)
+	}
+	found := len(removed) > 0
+	for task, cancel := range e.running {
+		if task.ID == taskID {
+			cancel(nil)
+			found = true
+		}
+	}
+	e.mu.Unlock()
+
+	for _, task := range removed {
+		e.cancelQueued(task, context.Canceled)
+	}
+	if !found {
+		return fmt.Errorf("task %s not found", taskID)
+	}
+	return nil
+}
+
+// cancelQueued 以取消状态结束已移出队列的任务（内部方法）
+func (e *GopoolExecutor) cancelQueued(task *Task, cause error) {
+	now := time.Now()
+	result := Result{
+		TaskID:    task.ID,
+		Error:     fmt.Errorf("task %s cancelled: %w", task.ID, cause),
+		StartTime: now,
+		EndTime:   now,
+		Status:    TaskCancelled,
+	}
+	if h := e.handle(task); h != nil {
+		e.forget(task)
+		h.finishIf(TaskQueued, result)
+	}
+	if task.OnCompletion != nil {
+		task.OnCompletion(result)
+	}
+	e.track(task.ID, TaskCancelled)
+}
+
+// track 将任务状态报告给 Monitor 与 Recovery，并统计最终状态（内部方法）
+func (e *GopoolExecutor) track(taskID string, status TaskStatus) {
+	if status.Finished() {
+		e.mu.Lock()
+		if e.finished == nil {
+			e.finished = make(map[TaskStatus]int)
+		}
+		e.finished[status]++
+		e.mu.Unlock()
+	}
+	if e.Recovery != nil {
+		e.Recovery.SaveTaskState(taskID, status.String())
+	}
+	if e.Monitor == nil {
+		return
+	}
+	switch status {
+	case TaskQueued:
+	case TaskRunning:
+		if err := e.Monitor.StartMonitoring(taskID); err != nil {
+			// 重试的任务已在监控中
+			e.setMonitorStatus(taskID, MonitorStatusRunning)
+		}
+	case TaskSucceeded:
+		e.Monitor.StopMonitoring(taskID)
+	default:
+		// 未执行即被取消的任务此前没有开始监控
+		e.Monitor.StartMonitoring(taskID)
+		e.Monitor.StopMonitoring(taskID)
+		if status == TaskCancelled {
+			e.setMonitorStatus(taskID, MonitorStatusCancelled)
+		} else {
+			e.setMonitorStatus(taskID, MonitorStatusFailed)
+		}
+	}
+}
+
+// setMonitorStatus 更新任务在 Monitor 中的状态，保留资源使用情况（内部方法）
+func (e *GopoolExecutor) setMonitorStatus(taskID string, status string) {
+	var usage ResourceUsage
+	if current, err := e.Monitor.GetTaskStatus(taskID); err == nil {
+		usage = current.ResourceUsage
+	}
+	e.Monitor.UpdateTaskStatus(taskID, status, usage)
+}
+
+// Submit 校验任务后将其加入任务队列，返回用于等待结果与取消任务的句柄
+// ctx 结束时任务被取消，与调用 TaskHandle.Cancel 的效果相同
+func (e *GopoolExecutor) Submit(ctx context.Context, task *Task) (*TaskHandle, error) {
+	if err := e.validateTask(task); err != nil {
+		return nil, fmt.Errorf("task %s rejected: %w", task.ID, err)
+	}
+	return e.submit(ctx, task)
+}
+
+// SubmitAll 提交一批任务，返回与 tasks 顺序对应的句柄
+// 任一任务校验失败时不提交任何任务；加入队列失败时已提交的任务会被取消
+func (e *GopoolExecutor) SubmitAll(ctx context.Context, tasks []*Task) ([]*TaskHandle, error) {
+	for _, task := range tasks {
+		if err := e.validateTask(task); err != nil {
+			return nil, fmt.Errorf("task %s rejected: %w", task.ID, err)
+		}
+	}
+	handles := make([]*TaskHandle, 0, len(tasks))
+	for _, task := range tasks {
+		h, err := e.submit(ctx, task)
+		if err != nil {
+			for _, h := range handles {
+				h.Cancel()
+			}
+			return nil, err
+		}
+		handles = append(handles, h)
+	}
+	return handles, nil
+}
+
+// submit 为已校验的任务创建句柄并加入任务队列（内部方法）
+func (e *GopoolExecutor) submit(ctx context.Context, task *Task) (*TaskHandle, error) {
+	e.handleMu.Lock()
+	if e.handles == nil {
+		e.handles = make(map[*Task]*TaskHandle)
+	}
+	if _, ok := e.handles[task]; ok {
+		e.handleMu.Unlock()
+		return nil, fmt.Errorf("task %s is already submitted", task.ID)
+	}
+	h := newTaskHandle(ctx, task)
+	e.handles[task] = h
+	e.handleMu.Unlock()
+
+	e.mu.Lock()
+	err := ErrExecutorClosed
+	if !e.closing {
+		err = e.Queue.AddTask(task)
+	}
+	e.mu.Unlock()
+	if err != nil {
+		e.forget(task)
+		h.Cancel()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-h.ctx.Done():
+			// 仍在排队的任务立即结束；正在执行的任务的 ctx 派生自 h.ctx，由执行器终止
+			e.mu.Lock()
+			removed := e.Queue.removeTask(task)
+			e.mu.Unlock()
+			if removed {
+				e.cancelQueued(task, h.ctx.Err())
+			}
+		case <-h.done:
 		}
 	}()
+	return h, nil
+}
+
+// handle 返回任务的句柄，任务不是通过 Submit 提交时返回 nil（内部方法）
+func (e *GopoolExecutor) handle(task *Task) *TaskHandle {
+	e.handleMu.Lock()
+	defer e.handleMu.Unlock()
+	return e.handles[task]
+}
+
+// forget 移除已结束任务的句柄（内部方法）
+func (e *GopoolExecutor) forget(task *Task) {
+	e.handleMu.Lock()
+	defer e.handleMu.Unlock()
+	delete(e.handles, task)
+}
+
+// AddTask 校验任务后将其加入任务队列
+// 找不到任务要求的解释器时任务会被直接拒绝，而不会进入队列；执行器开始关闭后返回 ErrExecutorClosed
+func (e *GopoolExecutor) AddTask(task *Task) error {
+	if err := e.validateTask(task); err != nil {
+		return fmt.Errorf("task %s rejected: %w", task.ID, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closing {
+		return ErrExecutorClosed
+	}
+	return e.Queue.AddTask(task)
+}
+
+// validateTask 校验任务能否被执行（内部方法）
+func (e *GopoolExecutor) validateTask(task *Task) error {
+	if err := task.validateSource(); err != nil {
+		return err
+	}
+	if err := task.validateEnv(); err != nil {
+		return err
+	}
+	if err := task.OutputLimits.validate(); err != nil {
+		return err
+	}
+	if err := task.Input.validate(e.InputLimits); err != nil {
+		return err
+	}
+	if err := validateOutputs(task.Outputs); err != nil {
+		return err
+	}
+	if err := task.Resources.validate(e.Capacity); err != nil {
+		return err
+	}
+	// 由实际执行任务的执行器校验解释器与依赖，避免拒绝该执行器能够执行的任务
+	executor := e.Executor
+	if executor == nil {
+		executor = e.newPythonExecutor()
+	}
+	if v, ok := executor.(interface {
+		validateEnvironment(task *Task) error
+	}); ok {
+		return v.validateEnvironment(task)
+	}
 	return nil
 }
 
+// newPythonExecutor 创建执行任务所用的 SecurePythonExecutor（内部方法）
+func (e *GopoolExecutor) newPythonExecutor() *SecurePythonExecutor {
+	return &SecurePythonExecutor{
+		Venvs:        e.Venvs,
+		Pip:          e.Pip,
+		Interpreter:  e.Interpreter,
+		Interpreters: e.Interpreters,
+
+		KillGracePeriod: e.KillGracePeriod,
+		CgroupParent:    e.CgroupParent,
+		Seccomp:         e.Seccomp,
+		InputLimits:     e.InputLimits,
+		Artifacts:       e.Artifacts,
+		ArtifactLimits:  e.ArtifactLimits,
+		OutputLimits:    e.OutputLimits,
+	}
+}
+
 // ExecuteTask 执行单个任务（内部方法）
 func (e *GopoolExecutor) ExecuteTask(task *Task) Result {
+	return e.executeTask(context.Background(), task)
+}
+
+// executeTask 在 ctx 下执行单个任务，ctx 结束时任务被终止（内部方法）
+func (e *GopoolExecutor) executeTask(ctx context.Context, task *Task) Result {
+	defer secrets.register(task)()
 	result := Result{
 		TaskID:    task.ID,
 		StartTime: time.Now(),
 	}
 
-	executor := &SecurePythonExecutor{}
-	err := executor.SetupEnvironment(task.ID) // 使用任务ID作为虚拟环境名称
-	if err != nil {
-		result.Error = fmt.Errorf("failed to setup environment: %v", err)
-		return result
+	var executor PythonExecutor = e.newPythonExecutor()
+	if e.Executor != nil {
+		executor = e.Executor
 	}
-
-	output, err := executor.Execute(task.Script, task.Args, task.Timeout)
+	res, err := executor.Stream(ctx, task, e.Output)
 
 	result.EndTime = time.Now()
-	result.Output = output
+	if res != nil {
+		result.ExecutionResult = *res
+		result.Output = res.Stdout + res.Stderr
+	}
 	result.Error = err
+	switch {
+	case err == nil:
+		result.Status = TaskSucceeded
+	case ctx.Err() != nil:
+		result.Status = TaskCancelled
+	default:
+		result.Status = TaskFailed
+	}
 
 	if task.OnCompletion != nil {
 		task.OnCompletion(result)
@@ -111,9 +570,20 @@ func (e *GopoolExecutor) ExecuteTask(task *Task) Result {
 
 // GetStats 获取执行器的统计信息
 func (e *GopoolExecutor) GetStats() map[string]interface{} {
-	return map[string]interface{}{
-		"running_workers": e.pool.Running(),
-		"total_workers":   e.pool.GetWorkerCount(),
-		"queue_size":      e.Queue.Size(),
+	e.mu.Lock()
+	pool := e.pool
+	stats := map[string]interface{}{
+		"state":        e.stateLocked(),
+		"paused":       e.paused,
+		"pool_size":    e.size,
+		"active_tasks": e.active,
+		"memory_hold":  e.memoryHold,
+		"reserved":     *e.reserved.clone(),
 	}
+	e.mu.Unlock()
+
+	stats["running_workers"] = pool.Running()
+	stats["total_workers"] = pool.GetWorkerCount()
+	stats["queue_size"] = e.Queue.Size()
+	return stats
 }
@@ -0,0 +1,94 @@
+package pyExecuter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// SampleHostLoad 从 /proc 读取主机负载：loadavg、meminfo 与 PSI，内核不提供 PSI 时 HostLoad.PSI 为 false
+func SampleHostLoad() (HostLoad, error) {
+	load := HostLoad{CPUs: runtime.NumCPU()}
+
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return load, fmt.Errorf("failed to read load average: %v", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return load, fmt.Errorf("malformed /proc/loadavg")
+	}
+	if load.Load1, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return load, fmt.Errorf("malformed /proc/loadavg: %v", err)
+	}
+
+	if load.MemTotalBytes, load.MemAvailableBytes, err = readMeminfo(); err != nil {
+		return load, err
+	}
+
+	cpu, cpuErr := readPressure("/proc/pressure/cpu")
+	memory, memErr := readPressure("/proc/pressure/memory")
+	if cpuErr == nil && memErr == nil {
+		load.PSI = true
+		load.CPUPressure, load.MemoryPressure = cpu, memory
+	}
+	return load, nil
+}
+
+// readMeminfo 读取 /proc/meminfo 中的内存总量与可用内存（字节）
+func readMeminfo() (total, available uint64, err error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory info: %v", err)
+	}
+	defer f.Close()
+
+	var haveTotal, haveAvailable bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, haveTotal = kb*1024, true
+		case "MemAvailable:":
+			available, haveAvailable = kb*1024, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, fmt.Errorf("failed to read memory info: %v", err)
+	}
+	if !haveTotal || !haveAvailable {
+		return 0, 0, fmt.Errorf("MemTotal or MemAvailable missing from /proc/meminfo")
+	}
+	return total, available, nil
+}
+
+// readPressure 读取 PSI 文件中 "some" 一行的 avg10
+func readPressure(path string) (float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg10="); ok {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("malformed %s", path)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pyExecuter
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SampleHostLoad 当前平台不支持读取主机负载，总是返回错误
+func SampleHostLoad() (HostLoad, error) {
+	return HostLoad{CPUs: runtime.NumCPU()}, fmt.Errorf("host load sampling is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,307 @@
+package pyExecuter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoInterpreter 找不到符合要求的Python解释器
+var ErrNoInterpreter = errors.New("no matching python interpreter")
+
+// InterpreterSelector 选择执行任务所用的Python解释器
+// Path 与 Version 均为空时使用 PATH 中的 "python"
+type InterpreterSelector struct {
+	Path    string // 解释器的显式路径，优先于 Version
+	Version string // 版本约束，例如 "3.11"、">=3.8,<3.12"
+}
+
+// IsZero 判断是否未指定任何选择条件
+func (s InterpreterSelector) IsZero() bool {
+	return s.Path == "" && s.Version == ""
+}
+
+// Interpreter 主机上的一个Python解释器
+type Interpreter struct {
+	Path    string // 解释器路径
+	Version string // 版本号，例如 "3.11.7"
+}
+
+// InterpreterRegistry 发现主机上的Python解释器并按选择条件解析
+type InterpreterRegistry struct {
+	SearchPaths []string // 额外搜索的目录，PATH 中的目录总会被搜索
+
+	interpreters []Interpreter
+	discovered   bool
+	versions     map[string]interpreterVersion // 解释器路径到版本的缓存
+	mu           sync.Mutex
+}
+
+// interpreterVersion 缓存的解释器版本，stamp 变化（例如原地升级）后缓存失效
+type interpreterVersion struct {
+	stamp   string
+	version string
+}
+
+// defaultInterpreters 未配置 InterpreterRegistry 时共享的默认实例
+var defaultInterpreters = NewInterpreterRegistry()
+
+// NewInterpreterRegistry 创建 InterpreterRegistry 实例
+func NewInterpreterRegistry(searchPaths ...string) *InterpreterRegistry {
+	return &InterpreterRegistry{
+		SearchPaths: searchPaths,
+		versions:    make(map[string]interpreterVersion),
+	}
+}
+
+// Discover 返回主机上发现的解释器，按版本从高到低排序
+// 发现的解释器会被缓存，再次调用时只重新查询文件已变化的解释器的版本；无法运行的候选（例如未激活的 pyenv shim）会被忽略
+func (r *InterpreterRegistry) Discover() []Interpreter {
+	r.mu.Lock()
+	if r.discovered {
+		cached := append([]Interpreter(nil), r.interpreters...)
+		r.mu.Unlock()
+		var found []Interpreter
+		for _, interp := range cached {
+			if version, err := r.Version(interp.Path); err == nil {
+				found = append(found, Interpreter{Path: interp.Path, Version: version})
+			}
+		}
+		sortInterpreters(found)
+		return found
+	}
+	dirs := append(append([]string(nil), r.SearchPaths...), filepath.SplitList(os.Getenv("PATH"))...)
+	r.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var found []Interpreter
+	for _, dir := range dirs {
+		candidates, _ := filepath.Glob(filepath.Join(dir, "python*"))
+		for _, candidate := range candidates {
+			name := filepath.Base(candidate)
+			if name != "python" && name != "python3" && !isVersionedPythonName(name) {
+				continue
+			}
+			realPath, err := filepath.EvalSymlinks(candidate)
+			if err != nil || seen[realPath] {
+				continue
+			}
+			seen[realPath] = true
+			version, err := r.Version(candidate)
+			if err != nil {
+				continue
+			}
+			found = append(found, Interpreter{Path: candidate, Version: version})
+		}
+	}
+	sortInterpreters(found)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interpreters = found
+	r.discovered = true
+	return append([]Interpreter(nil), found...)
+}
+
+// Resolve 按选择条件解析解释器
+// 指定 Path 时直接使用该解释器，否则在已发现的解释器中选择满足版本约束的最高版本
+func (r *InterpreterRegistry) Resolve(sel InterpreterSelector) (Interpreter, error) {
+	var constraint versionConstraint
+	if sel.Version != "" {
+		var err error
+		if constraint, err = parseVersionConstraint(sel.Version); err != nil {
+			return Interpreter{}, err
+		}
+	}
+
+	if sel.Path != "" || sel.Version == "" {
+		path := sel.Path
+		if path == "" {
+			path = "python"
+		}
+		version, err := r.Version(path)
+		if err != nil {
+			return Interpreter{}, fmt.Errorf("%w: %v", ErrNoInterpreter, err)
+		}
+		if constraint != nil && !constraint.matches(parseVersion(version)) {
+			return Interpreter{}, fmt.Errorf("%w: %s is version %s, want %s", ErrNoInterpreter, path, version, sel.Version)
+		}
+		return Interpreter{Path: path, Version: version}, nil
+	}
+
+	for _, interp := range r.Discover() {
+		if constraint.matches(parseVersion(interp.Version)) {
+			return interp, nil
+		}
+	}
+	return Interpreter{}, fmt.Errorf("%w: version %s", ErrNoInterpreter, sel.Version)
+}
+
+// Version 查询并缓存解释器的版本号
+// 缓存以解析后的文件路径、修改时间与大小为准，原地升级解释器后会重新查询
+func (r *InterpreterRegistry) Version(path string) (string, error) {
+	stamp := interpreterStamp(path)
+	r.mu.Lock()
+	cached, ok := r.versions[path]
+	r.mu.Unlock()
+	if ok && stamp != "" && cached.stamp == stamp {
+		return cached.version, nil
+	}
+
+	output, err := exec.Command(path, "-c", "import platform; print(platform.python_version())").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query version of interpreter %s: %v", path, err)
+	}
+	version := strings.TrimSpace(string(output))
+
+	r.mu.Lock()
+	r.versions[path] = interpreterVersion{stamp: stamp, version: version}
+	r.mu.Unlock()
+	return version, nil
+}
+
+// interpreterStamp 返回标识解释器文件的字符串：按 PATH 解析并跟随符号链接后文件的路径、修改时间与大小
+// 无法解析时返回空字符串，此时不使用缓存
+func interpreterStamp(path string) string {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return ""
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", resolved, info.ModTime().UnixNano(), info.Size())
+}
+
+// sortInterpreters 按版本从高到低排序
+func sortInterpreters(interpreters []Interpreter) {
+	sort.SliceStable(interpreters, func(i, j int) bool {
+		return compareVersions(parseVersion(interpreters[i].Version), parseVersion(interpreters[j].Version)) > 0
+	})
+}
+
+// isVersionedPythonName 判断文件名是否形如 python3.11
+func isVersionedPythonName(name string) bool {
+	rest := strings.TrimPrefix(name, "python")
+	if rest == name || rest == "" {
+		return false
+	}
+	for _, part := range strings.Split(rest, ".") {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVersion 将 "3.11.7" 解析为数字序列，忽略无法解析的后缀
+func parseVersion(version string) []int {
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		digits := field
+		for i, c := range field {
+			if c < '0' || c > '9' {
+				digits = field[:i]
+				break
+			}
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersions 比较两个版本，缺失的分量视为0
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionClause 版本约束中的一个条件
+type versionClause struct {
+	op      string
+	version []int
+}
+
+// versionConstraint 由逗号分隔的多个条件组成，需全部满足
+type versionConstraint []versionClause
+
+// parseVersionConstraint 解析版本约束
+// 不带运算符或使用 "==" 的条件按前缀匹配，例如 "3.11" 匹配 3.11.x
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var constraint versionConstraint
+	for _, raw := range strings.Split(s, ",") {
+		raw = strings.TrimSpace(raw)
+		op := "=="
+		for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<"} {
+			if strings.HasPrefix(raw, candidate) {
+				op = candidate
+				raw = strings.TrimSpace(raw[len(candidate):])
+				break
+			}
+		}
+		version := parseVersion(raw)
+		if len(version) == 0 {
+			return nil, fmt.Errorf("invalid python version constraint %q", s)
+		}
+		constraint = append(constraint, versionClause{op: op, version: version})
+	}
+	return constraint, nil
+}
+
+// matches 判断版本是否满足约束
+// "==" 与 "!=" 按前缀比较，其余运算符比较完整版本，例如 ">3.8" 接受 3.8.5，"<=3.8" 拒绝 3.8.12
+func (c versionConstraint) matches(version []int) bool {
+	for _, clause := range c {
+		prefix := version
+		if len(prefix) > len(clause.version) {
+			prefix = prefix[:len(clause.version)]
+		}
+		var ok bool
+		switch clause.op {
+		case "==":
+			ok = compareVersions(prefix, clause.version) == 0
+		case "!=":
+			ok = compareVersions(prefix, clause.version) != 0
+		case ">=":
+			ok = compareVersions(version, clause.version) >= 0
+		case "<=":
+			ok = compareVersions(version, clause.version) <= 0
+		case ">":
+			ok = compareVersions(version, clause.version) > 0
+		case "<":
+			ok = compareVersions(version, clause.version) < 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
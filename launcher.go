@@ -0,0 +1,20 @@
+package pyExecuter
+
+import (
+	"errors"
+	"strings"
+)
+
+// launcherFailed 启动器在执行Python前失败时的退出码
+const launcherFailed = 120
+
+// ErrLauncherNotEnabled 使用沙箱或 seccomp 的程序没有在 main 的开始处调用 RunLauncher
+var ErrLauncherNotEnabled = errors.New("sandbox and seccomp require calling pyExecuter.RunLauncher at the start of main")
+
+// launcherFailure 判断执行结果是否表示启动器在 prefix 对应的阶段失败，并返回失败原因
+func launcherFailure(res *ExecutionResult, prefix string) (string, bool) {
+	if res == nil || res.ExitCode != launcherFailed || !strings.HasPrefix(res.Stderr, prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(res.Stderr, prefix)), true
+}
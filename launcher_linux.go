@@ -0,0 +1,76 @@
+package pyExecuter
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// launcherArg 启动器进程的 argv[0]，用于在重新执行自身时识别启动器模式
+const launcherArg = "pyexecuter-launcher"
+
+// launcherEnabled 当前程序调用过 RunLauncher，重新执行自身时会进入启动器
+var launcherEnabled atomic.Bool
+
+// RunLauncher 启动器的入口，使用沙箱或 seccomp 的程序必须在 main 的开始处调用
+// 执行器通过重新执行当前程序来启动Python：当前进程作为启动器被启动时，完成沙箱与 seccomp 等准备后执行Python，不会返回；
+// 其他情况下立即返回
+func RunLauncher() {
+	if len(os.Args) > 1 && os.Args[0] == launcherArg {
+		launch()
+	}
+	launcherEnabled.Store(true)
+}
+
+// useLauncher 将命令改为经由启动器执行，已经包装过的命令保持不变
+// 当前程序没有调用 RunLauncher 时重新执行自身无法进入启动器，返回 ErrLauncherNotEnabled
+func useLauncher(cmd *exec.Cmd) error {
+	if !launcherEnabled.Load() {
+		return ErrLauncherNotEnabled
+	}
+	if len(cmd.Args) > 0 && cmd.Args[0] == launcherArg {
+		return nil
+	}
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = append([]string{launcherArg}, cmd.Args...)
+	return nil
+}
+
+// launch 启动器的入口，按环境变量中的配置依次构建沙箱、安装 seccomp 过滤器并执行Python
+func launch() {
+	// 能力、安全位与 seccomp 过滤器都是线程级别的，准备工作与 exec 必须在同一线程中完成
+	runtime.LockOSThread()
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sandboxSpecEnv+"=") && !strings.HasPrefix(kv, seccompFilterEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	prefix := seccompErrorPrefix
+	if spec, ok := os.LookupEnv(sandboxSpecEnv); ok {
+		prefix = sandboxErrorPrefix
+		if err := setupSandbox(spec); err != nil {
+			launcherExit(sandboxErrorPrefix, err)
+		}
+	}
+	if filter, ok := os.LookupEnv(seccompFilterEnv); ok {
+		if err := installSeccomp(filter); err != nil {
+			launcherExit(seccompErrorPrefix, err)
+		}
+	}
+
+	argv := os.Args[1:]
+	launcherExit(prefix, fmt.Errorf("failed to exec %s: %v", argv[0], syscall.Exec(argv[0], argv, env)))
+}
+
+// launcherExit 报告启动器的错误并退出
+func launcherExit(prefix string, err error) {
+	fmt.Fprintf(os.Stderr, "%s%v\n", prefix, err)
+	os.Exit(launcherFailed)
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package pyExecuter
+
+// RunLauncher 启动器的入口，其他平台不支持沙箱与 seccomp，调用后立即返回
+func RunLauncher() {}
@@ -0,0 +1,159 @@
+package pyExecuter
+
+import (
+	"fmt"
+)
+
+// LimitOutput 输出超过上限且策略为 OutputKill 时 ExecutionResult.LimitExceeded 的取值
+const LimitOutput = "output"
+
+// OutputPolicy 输出超过上限时的处理策略
+type OutputPolicy int
+
+const (
+	OutputKeepHead     OutputPolicy = iota // 保留开头的输出（默认）
+	OutputKeepTail                         // 保留最后的输出
+	OutputKeepHeadTail                     // 各保留一半开头与结尾的输出，中间插入截断说明
+	OutputKill                             // 终止任务，保留已收集的开头部分
+)
+
+// String 返回策略的名称
+func (p OutputPolicy) String() string {
+	switch p {
+	case OutputKeepHead:
+		return "head"
+	case OutputKeepTail:
+		return "tail"
+	case OutputKeepHeadTail:
+		return "head_tail"
+	case OutputKill:
+		return "kill"
+	}
+	return fmt.Sprintf("OutputPolicy(%d)", int(p))
+}
+
+// OutputLimits 任务标准输出与标准错误的大小上限
+// 上限只作用于结果中保存的输出，实时分发到 OutputStream 的输出不受影响
+type OutputLimits struct {
+	StdoutBytes int64        // 标准输出的上限（字节），0 表示不限制
+	StderrBytes int64        // 标准错误的上限（字节），0 表示不限制
+	Policy      OutputPolicy // 超过上限时的处理策略
+}
+
+// DefaultOutputLimits 任务与执行器都未设置输出上限时使用的上限，避免输出过多的任务耗尽内存
+// 需要不限制输出时应显式设置值为零的 OutputLimits
+var DefaultOutputLimits = OutputLimits{StdoutBytes: 16 << 20, StderrBytes: 16 << 20}
+
+// validate 校验输出限制
+func (l *OutputLimits) validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.StdoutBytes < 0 || l.StderrBytes < 0 {
+		return fmt.Errorf("invalid output limits: negative size")
+	}
+	if l.Policy < OutputKeepHead || l.Policy > OutputKill {
+		return fmt.Errorf("invalid output policy %v", l.Policy)
+	}
+	return nil
+}
+
+// outputLimits 返回任务使用的输出限制，任务未设置时使用执行器的默认值，两者都未设置时使用 DefaultOutputLimits
+func (t *Task) outputLimits(def *OutputLimits) OutputLimits {
+	if t.OutputLimits != nil {
+		return *t.OutputLimits
+	}
+	if def != nil {
+		return *def
+	}
+	return DefaultOutputLimits
+}
+
+// truncationMarker 保留开头与结尾时插入两者之间的说明
+func truncationMarker(n int64) string {
+	return fmt.Sprintf("\n[... %d bytes truncated ...]\n", n)
+}
+
+// cappedBuffer 按上限与策略保存输出的 io.Writer，同时统计实际写入的字节数
+type cappedBuffer struct {
+	max      int64        // 上限，0 表示不限制
+	policy   OutputPolicy // 超过上限时的策略
+	overflow func()       // 策略为 OutputKill 时超过上限后调用一次
+
+	head  []byte
+	tail  []byte
+	total int64
+}
+
+// newCappedBuffer 创建 cappedBuffer 实例
+func newCappedBuffer(max int64, policy OutputPolicy, overflow func()) *cappedBuffer {
+	return &cappedBuffer{max: max, policy: policy, overflow: overflow}
+}
+
+// split 返回开头与结尾部分各自保留的字节数
+func (b *cappedBuffer) split() (int64, int64) {
+	switch b.policy {
+	case OutputKeepTail:
+		return 0, b.max
+	case OutputKeepHeadTail:
+		return b.max / 2, b.max - b.max/2
+	}
+	return b.max, 0
+}
+
+// Write 实现 io.Writer 接口，超过上限的部分按策略丢弃，但总是报告写入成功
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.total += int64(n)
+	if b.max <= 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+
+	headMax, tailMax := b.split()
+	if room := headMax - int64(len(b.head)); room > 0 {
+		k := int64(len(p))
+		if k > room {
+			k = room
+		}
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	if tailMax > 0 && len(p) > 0 {
+		b.tail = append(b.tail, p...)
+		// 超过两倍上限时才移动数据，均摊开销与写入量成正比
+		if int64(len(b.tail)) > 2*tailMax {
+			b.tail = append(b.tail[:0], b.tail[int64(len(b.tail))-tailMax:]...)
+		}
+	}
+
+	if b.total > b.max && b.policy == OutputKill && b.overflow != nil {
+		b.overflow()
+		b.overflow = nil
+	}
+	return n, nil
+}
+
+// Truncated 返回输出是否超过了上限
+func (b *cappedBuffer) Truncated() bool {
+	return b.max > 0 && b.total > b.max
+}
+
+// Total 返回实际写入的字节数
+func (b *cappedBuffer) Total() int64 {
+	return b.total
+}
+
+// String 返回按策略保存的输出
+func (b *cappedBuffer) String() string {
+	_, tailMax := b.split()
+	tail := b.tail
+	if b.max > 0 && int64(len(tail)) > tailMax {
+		tail = tail[int64(len(tail))-tailMax:]
+	}
+	if b.Truncated() && b.policy == OutputKeepHeadTail {
+		dropped := b.total - int64(len(b.head)) - int64(len(tail))
+		return string(b.head) + truncationMarker(dropped) + string(tail)
+	}
+	return string(b.head) + string(tail)
+}
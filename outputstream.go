@@ -0,0 +1,181 @@
+package pyExecuter
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 输出流名称
+const (
+	StreamStdout = "stdout" // 标准输出
+	StreamStderr = "stderr" // 标准错误输出
+)
+
+// DefaultStreamBuffer 每个订阅者默认可缓冲的输出块数
+const DefaultStreamBuffer = 1024
+
+// maxPendingLine 按行分发时单行的最大缓冲长度，超过后按块分发
+const maxPendingLine = 64 * 1024
+
+// StreamMode 输出分发的粒度
+type StreamMode int
+
+const (
+	StreamLines  StreamMode = iota // 按行分发
+	StreamChunks                   // 按读取到的数据块分发
+)
+
+// OutputChunk 运行中的任务产生的一段输出
+type OutputChunk struct {
+	TaskID    string    // 对应任务的ID
+	Stream    string    // 输出流名称（stdout 或 stderr）
+	Data      []byte    // 输出内容
+	Timestamp time.Time // 输出产生的时间
+}
+
+// OutputStream 将运行中任务的输出分发给订阅者
+// 每个订阅者拥有独立的有界缓冲区，缓冲区满时丢弃新数据，因此慢速消费者不会阻塞Python进程
+type OutputStream struct {
+	Mode       StreamMode // 分发粒度
+	BufferSize int        // 每个订阅者的缓冲区大小（块数）
+
+	subscribers map[int]chan OutputChunk
+	nextID      int
+	dropped     uint64
+	mu          sync.RWMutex
+}
+
+// NewOutputStream 创建 OutputStream 实例
+func NewOutputStream(mode StreamMode, bufferSize int) *OutputStream {
+	if bufferSize <= 0 {
+		bufferSize = DefaultStreamBuffer
+	}
+	return &OutputStream{
+		Mode:        mode,
+		BufferSize:  bufferSize,
+		subscribers: make(map[int]chan OutputChunk),
+	}
+}
+
+// SubscribeChan 订阅输出，返回接收输出块的channel以及取消订阅函数
+// 取消订阅后channel会被关闭
+func (s *OutputStream) SubscribeChan() (<-chan OutputChunk, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscribers == nil {
+		s.subscribers = make(map[int]chan OutputChunk)
+	}
+	size := s.BufferSize
+	if size <= 0 {
+		size = DefaultStreamBuffer
+	}
+	id := s.nextID
+	s.nextID++
+	ch := make(chan OutputChunk, size)
+	s.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, id)
+			close(ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+// Subscribe 以回调函数订阅输出，返回取消订阅函数
+// 回调在独立的goroutine中按输出顺序依次调用
+func (s *OutputStream) Subscribe(fn func(chunk OutputChunk)) func() {
+	ch, unsubscribe := s.SubscribeChan()
+	go func() {
+		for chunk := range ch {
+			fn(chunk)
+		}
+	}()
+	return unsubscribe
+}
+
+// Dropped 返回因订阅者处理过慢而被丢弃的输出块数
+func (s *OutputStream) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// publish 将输出块非阻塞地投递给所有订阅者
+func (s *OutputStream) publish(chunk OutputChunk) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- chunk:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// writer 返回将某个任务的某个输出流写入 OutputStream 的 streamWriter
+func (s *OutputStream) writer(taskID, stream string) *streamWriter {
+	return &streamWriter{out: s, taskID: taskID, stream: stream}
+}
+
+// streamWriter 实现 io.Writer，将写入的数据按分发粒度切分后发布
+type streamWriter struct {
+	out     *OutputStream
+	taskID  string
+	stream  string
+	pending []byte
+	mu      sync.Mutex
+}
+
+// Write 实现 io.Writer 接口
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.out.Mode == StreamChunks {
+		w.emit(p)
+		return len(p), nil
+	}
+
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.pending[:i+1])
+		w.pending = w.pending[i+1:]
+	}
+	if len(w.pending) >= maxPendingLine {
+		w.emit(w.pending)
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+// Flush 发布尚未以换行结束的剩余输出
+func (w *streamWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) > 0 {
+		w.emit(w.pending)
+		w.pending = nil
+	}
+}
+
+// emit 复制数据并发布一个输出块
+func (w *streamWriter) emit(data []byte) {
+	w.out.publish(OutputChunk{
+		TaskID:    w.taskID,
+		Stream:    w.stream,
+		Data:      append([]byte(nil), data...),
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,730 @@
+package pyExecuter
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultWorkerPoolSize 每个虚拟环境默认保持的 worker 数量
+const DefaultWorkerPoolSize = 4
+
+// DefaultMaxTasksPerWorker worker 默认执行多少个任务后被回收
+const DefaultMaxTasksPerWorker = 100
+
+// workerFileName worker 脚本的文件名
+const workerFileName = "_pyexecuter_worker.py"
+
+// workerStderrTail 保留 worker 自身标准错误输出的字节数，用于诊断崩溃
+const workerStderrTail = 4096
+
+// maxWorkerMessage 单条协议消息的最大长度
+const maxWorkerMessage = 1 << 30
+
+// workerScript 常驻 worker 的脚本内容
+//
+//go:embed worker.py
+var workerScript []byte
+
+// ErrExecutorClosed 执行器已关闭
+var ErrExecutorClosed = errors.New("executor is closed")
+
+// PooledPythonExecutor 为每个虚拟环境保持一组预先启动的Python worker 进程的 PythonExecutor 实现
+// 脚本通过管道发送给空闲的 worker，在全新的 __main__ 命名空间中执行，省去了启动解释器的开销；
+// 已导入的模块会在任务间保留。worker 在执行 MaxTasksPerWorker 个任务后、内存超过 MaxWorkerMemory 后、
+// 任务结束后仍有存活的线程或进程时或发生任何崩溃（包括超时被终止）后被回收，并在后台启动新的 worker 补充，
+// 回收时终止 worker 的整个进程组，任务启动的进程不会留给下一个任务
+// 设置了 Limits 的任务需要独立的进程，从文件、归档或入口点运行以及设置了 PythonPath 的任务
+// 导入的模块不应在任务间共享，输出超限时需要终止的任务也无法在 worker 中处理，
+// 这些任务都会退回到 SecurePythonExecutor 的执行方式
+type PooledPythonExecutor struct {
+	SecurePythonExecutor // 环境管理、seccomp 等沿用 SecurePythonExecutor 的配置
+
+	PoolSize          int   // 每个虚拟环境的 worker 数量，即同一环境的最大并发数，默认 DefaultWorkerPoolSize
+	MaxTasksPerWorker int   // 每个 worker 最多执行的任务数，默认 DefaultMaxTasksPerWorker
+	MaxWorkerMemory   int64 // worker 常驻内存（字节）超过该值后被回收，0表示不限制
+
+	pools  map[string]*workerPool
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewPooledPythonExecutor 创建 PooledPythonExecutor 实例，size 为每个虚拟环境的 worker 数量
+func NewPooledPythonExecutor(venvs *VenvManager, size int) *PooledPythonExecutor {
+	return &PooledPythonExecutor{
+		SecurePythonExecutor: SecurePythonExecutor{Venvs: venvs},
+		PoolSize:             size,
+		pools:                make(map[string]*workerPool),
+	}
+}
+
+// Execute 执行Python脚本，返回标准输出与标准错误的拼接
+func (p *PooledPythonExecutor) Execute(script string, args []string, timeout time.Duration) (string, error) {
+	res, err := p.Run(context.Background(), &Task{Script: script, Args: args, Timeout: timeout})
+	if err != nil {
+		return "", err
+	}
+	return res.Stdout + res.Stderr, nil
+}
+
+// Run 在空闲的 worker 中执行任务，返回结构化的执行结果
+func (p *PooledPythonExecutor) Run(ctx context.Context, task *Task) (*ExecutionResult, error) {
+	return p.Stream(ctx, task, nil)
+}
+
+// Stream 在空闲的 worker 中执行任务，并在运行过程中将输出分发到 out
+func (p *PooledPythonExecutor) Stream(ctx context.Context, task *Task, out *OutputStream) (*ExecutionResult, error) {
+	limits := task.outputLimits(p.OutputLimits)
+	if task.Limits != nil || task.sourceKind() != sourceScript || len(task.PythonPath) > 0 || limits.Policy == OutputKill {
+		return p.SecurePythonExecutor.Stream(ctx, task, out)
+	}
+	if err := p.validateTask(task); err != nil {
+		return nil, err
+	}
+	defer secrets.register(task)()
+
+	envDir, version, release, err := p.acquireEnvironment(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := p.pool(envDir, release)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+
+	req := workerRequest{Script: task.Script, Args: task.Args}
+	if limits.StdoutBytes > 0 || limits.StderrBytes > 0 {
+		req.Output = &workerOutput{Stdout: limits.StdoutBytes, Stderr: limits.StderrBytes, Policy: limits.Policy.String()}
+	}
+	workDir := ""
+	if task.Input != nil || len(task.Outputs) > 0 {
+		// worker 的工作目录在任务间共享，输入与产物使用每个任务独立的临时目录
+		workDir, err = os.MkdirTemp("", "python_task_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create task directory: %v", err)
+		}
+		defer os.RemoveAll(workDir)
+	}
+	if task.Input != nil {
+		stdinPath, err := task.Input.materialize(workDir, p.InputLimits)
+		if err != nil {
+			return nil, err
+		}
+		req.Input = &workerInput{Dir: workDir, Stdin: stdinPath, Payload: payloadPath(workDir)}
+	}
+	var onOutput func(stream, data string)
+	if out != nil {
+		// worker 读取到输出后立即通过协议管道发送
+		req.Stream = true
+		writers := map[string]*streamWriter{
+			StreamStdout: out.writer(task.ID, StreamStdout),
+			StreamStderr: out.writer(task.ID, StreamStderr),
+		}
+		for _, w := range writers {
+			defer w.Flush()
+		}
+		onOutput = func(stream, data string) {
+			if w := writers[stream]; w != nil {
+				w.Write([]byte(data))
+			}
+		}
+	}
+	req.Cwd = task.scriptDir(workDir)
+	req.Env = make(map[string]string)
+	for _, kv := range task.environ(envDir) {
+		name, value, _ := strings.Cut(kv, "=")
+		req.Env[name] = value
+	}
+
+	w, err := pool.get(ctx)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("execution timed out after %v waiting for a worker", task.Timeout)
+		}
+		return nil, err
+	}
+
+	grace := p.KillGracePeriod
+	if grace <= 0 {
+		grace = DefaultKillGracePeriod
+	}
+	start := time.Now()
+	resp, err := w.run(ctx, req, grace, onOutput)
+	pool.put(w, err == nil && !resp.Leftover && resp.RSS <= p.maxWorkerMemory())
+
+	res := &ExecutionResult{
+		ExitCode: -1,
+		WallTime: time.Since(start),
+
+		PythonVersion: version,
+	}
+	var decodeErr error
+	if err == nil {
+		res.Stdout, res.Stderr, res.ExitCode = resp.Stdout, resp.Stderr, resp.ExitCode
+		res.StdoutBytes, res.StderrBytes = resp.StdoutBytes, resp.StderrBytes
+		res.StdoutTruncated = limits.StdoutBytes > 0 && resp.StdoutBytes > limits.StdoutBytes
+		res.StderrTruncated = limits.StderrBytes > 0 && resp.StderrBytes > limits.StderrBytes
+		res.CPUTime = time.Duration(resp.CPU * float64(time.Second))
+		if len(resp.Exception) > 0 {
+			res.Exception = parsePythonError(resp.Exception, ScriptFileName, task.Script)
+		}
+		if resp.Result != nil {
+			decodeErr = decodeResult(res, []byte(*resp.Result))
+		}
+	} else if state := w.cmd.ProcessState; state != nil {
+		// worker 在执行过程中退出，只能得到进程的退出状态与其自身的错误输出
+		res.ExitCode = state.ExitCode()
+		res.CPUTime = state.UserTime() + state.SystemTime()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			res.Signal = status.Signal()
+		}
+		res.Stderr = w.stderr.String()
+		if p.Seccomp != nil {
+			detectSeccompViolation(res)
+		}
+		if onOutput != nil && res.Stderr != "" {
+			onOutput(StreamStderr, res.Stderr)
+		}
+	}
+
+	var artifactErr error
+	if workDir != "" {
+		res.Artifacts, artifactErr = collectArtifacts(context.Background(), p.Artifacts, p.ArtifactLimits, task, workDir)
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.TimedOut = true
+		return res, fmt.Errorf("execution timed out after %v", task.Timeout)
+	case ctx.Err() != nil:
+		return res, fmt.Errorf("execution cancelled: %w", ctx.Err())
+	case res.SeccompViolation:
+		return res, fmt.Errorf("execution killed by seccomp: %w", err)
+	case err != nil:
+		return res, fmt.Errorf("execution failed: %w", err)
+	case res.Exception != nil:
+		return res, fmt.Errorf("execution failed: %w", res.Exception)
+	case res.ExitCode != 0:
+		return res, fmt.Errorf("execution failed: exit status %d", res.ExitCode)
+	case decodeErr != nil:
+		return res, decodeErr
+	case artifactErr != nil:
+		return res, artifactErr
+	}
+	return res, nil
+}
+
+// Close 终止所有 worker 并归还其占用的虚拟环境，关闭后的执行器不能再执行任务
+func (p *PooledPythonExecutor) Close() error {
+	p.mu.Lock()
+	pools := p.pools
+	p.pools = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, pool := range pools {
+		pool.close()
+	}
+	return nil
+}
+
+// Workers 返回各虚拟环境中当前存活的 worker 数量，键为虚拟环境目录
+func (p *PooledPythonExecutor) Workers() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	counts := make(map[string]int, len(p.pools))
+	for dir, pool := range p.pools {
+		counts[dir] = pool.size()
+	}
+	return counts
+}
+
+// pool 返回虚拟环境对应的 worker 池，不存在时创建并预先启动 worker
+// 新建的池持有 release，直到执行器关闭才归还虚拟环境，其他情况下立即归还
+func (p *PooledPythonExecutor) pool(envDir string, release func()) (*workerPool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		release()
+		return nil, ErrExecutorClosed
+	}
+	if pool, ok := p.pools[envDir]; ok {
+		release()
+		return pool, nil
+	}
+	if p.pools == nil {
+		p.pools = make(map[string]*workerPool)
+	}
+
+	size := p.PoolSize
+	if size <= 0 {
+		size = DefaultWorkerPoolSize
+	}
+	pool := &workerPool{
+		envDir:   envDir,
+		maxTasks: p.MaxTasksPerWorker,
+		seccomp:  p.Seccomp,
+		idle:     make(chan *worker, size),
+		slots:    make(chan struct{}, size),
+		release:  release,
+	}
+	if pool.maxTasks <= 0 {
+		pool.maxTasks = DefaultMaxTasksPerWorker
+	}
+	for i := 0; i < size; i++ {
+		go pool.refill()
+	}
+	p.pools[envDir] = pool
+	return pool, nil
+}
+
+// maxWorkerMemory 返回 worker 的内存上限
+func (p *PooledPythonExecutor) maxWorkerMemory() int64 {
+	if p.MaxWorkerMemory <= 0 {
+		return 1<<63 - 1
+	}
+	return p.MaxWorkerMemory
+}
+
+// workerPool 一个虚拟环境的 worker 池
+type workerPool struct {
+	envDir   string
+	maxTasks int
+	seccomp  *SeccompProfile
+
+	idle    chan *worker  // 空闲的 worker
+	slots   chan struct{} // 限制同时执行的任务数
+	release func()        // 归还虚拟环境
+
+	live   int
+	closed bool
+	mu     sync.Mutex
+}
+
+// get 占用一个执行槽位并返回空闲的 worker
+// 没有空闲 worker 且存活数未达上限时立即启动一个，否则等待正在启动或被回收后补充的 worker
+func (p *workerPool) get(ctx context.Context) (*worker, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		select {
+		case w := <-p.idle:
+			return w, nil
+		default:
+		}
+		w, err := p.start()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		if w != nil {
+			return w, nil
+		}
+		// 补充 worker 可能启动失败，定期重试
+		select {
+		case w := <-p.idle:
+			return w, nil
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			<-p.slots
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// put 归还 worker 并释放槽位，不健康或已达到任务上限的 worker 会被回收并在后台补充
+func (p *workerPool) put(w *worker, healthy bool) {
+	defer func() { <-p.slots }()
+
+	if !healthy || w.tasks >= p.maxTasks {
+		p.discard(w)
+		go p.refill()
+		return
+	}
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if !closed {
+		select {
+		case p.idle <- w:
+			return
+		default:
+		}
+	}
+	p.discard(w)
+}
+
+// refill 在存活数未达上限时启动一个新的 worker 放入空闲队列
+func (p *workerPool) refill() {
+	w, err := p.start()
+	if err != nil || w == nil {
+		return
+	}
+	select {
+	case p.idle <- w:
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			// close 可能已经清空了空闲队列
+			p.drain()
+		}
+	default:
+		p.discard(w)
+	}
+}
+
+// start 在存活数未达上限时启动一个 worker，已达上限时返回 nil
+func (p *workerPool) start() (*worker, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrExecutorClosed
+	}
+	if p.live >= cap(p.slots) {
+		p.mu.Unlock()
+		return nil, nil
+	}
+	p.live++
+	p.mu.Unlock()
+
+	w, err := startWorker(p.envDir, p.seccomp, nil)
+	if err != nil {
+		p.mu.Lock()
+		p.live--
+		p.mu.Unlock()
+		return nil, err
+	}
+	return w, nil
+}
+
+// discard 终止 worker
+func (p *workerPool) discard(w *worker) {
+	w.kill(0)
+	p.mu.Lock()
+	p.live--
+	p.mu.Unlock()
+}
+
+// size 返回存活的 worker 数量
+func (p *workerPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.live
+}
+
+// drain 终止所有空闲的 worker
+func (p *workerPool) drain() {
+	for {
+		select {
+		case w := <-p.idle:
+			p.discard(w)
+		default:
+			return
+		}
+	}
+}
+
+// close 关闭 worker 池，正在执行任务的 worker 在归还时被终止
+func (p *workerPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.drain()
+	p.release()
+}
+
+// workerRequest 发送给 worker 的请求
+type workerRequest struct {
+	Script string            `json:"script"`
+	Args   []string          `json:"args"`
+	Input  *workerInput      `json:"input,omitempty"`  // 任务输入，没有输入时省略
+	Cwd    string            `json:"cwd,omitempty"`    // 执行脚本时的当前目录，为空时不切换
+	Env    map[string]string `json:"env"`              // 脚本的全部环境变量
+	Output *workerOutput     `json:"output,omitempty"` // 输出上限，不限制时省略
+	Stream bool              `json:"stream,omitempty"` // 为 true 时 worker 在输出产生时即发送输出消息
+}
+
+// workerOutput 输出的上限与截断策略
+type workerOutput struct {
+	Stdout int64  `json:"stdout"` // 标准输出的上限，0 表示不限制
+	Stderr int64  `json:"stderr"` // 标准错误的上限，0 表示不限制
+	Policy string `json:"policy"` // OutputPolicy 的名称
+}
+
+// workerInput 已写入临时目录的任务输入
+type workerInput struct {
+	Dir     string `json:"dir"`     // 输入文件所在的目录
+	Stdin   string `json:"stdin"`   // 标准输入文件，没有标准输入时为空
+	Payload string `json:"payload"` // 负载文件的路径，文件不存在表示没有负载
+}
+
+// workerResponse worker 返回的执行结果
+type workerResponse struct {
+	Stdout      string  `json:"stdout"`       // 按输出上限截断后的标准输出
+	Stderr      string  `json:"stderr"`       // 按输出上限截断后的标准错误
+	StdoutBytes int64   `json:"stdout_bytes"` // 脚本实际写入标准输出的字节数
+	StderrBytes int64   `json:"stderr_bytes"` // 脚本实际写入标准错误的字节数
+	ExitCode    int     `json:"exit_code"`
+	CPU         float64 `json:"cpu"`      // 任务使用的CPU时间（秒）
+	RSS         int64   `json:"rss"`      // 任务结束后 worker 的常驻内存（字节）
+	Result      *string `json:"result"`   // 脚本写入结果通道的原始数据，未写入时为 nil
+	Leftover    bool    `json:"leftover"` // 任务结束后仍有存活的线程或进程，worker 需要回收
+
+	Exception json.RawMessage `json:"exception"` // 未捕获异常的描述，没有异常时为 null
+
+	// 以下字段只在会话模式下返回
+	ExecutionCount int     `json:"execution_count"` // 代码单元的序号，从1开始
+	Repr           *string `json:"repr"`            // 最后一个表达式的 repr()，没有值时为 nil
+	Value          *string `json:"value"`           // 最后一个表达式的 JSON 编码，无法编码时为 nil
+	Started        bool    `json:"started"`         // 为 true 时是代码单元开始执行的通知，其后才是最终响应
+
+	// 以下字段只在流式输出的消息中返回，Stream 不为空时该消息不是最终响应
+	Stream string `json:"stream"` // 输出流名称（stdout 或 stderr）
+	Data   string `json:"data"`   // 输出内容
+}
+
+// worker 一个常驻的Python进程
+type worker struct {
+	cmd       *exec.Cmd
+	dir       string
+	requests  *os.File
+	responses *os.File
+	reader    *bufio.Reader
+	stderr    *tailBuffer
+	tasks     int
+
+	exited chan struct{} // 进程退出后关闭
+	once   sync.Once
+}
+
+// startWorker 在虚拟环境中启动一个 worker 进程
+// session 不为 nil 时以会话模式启动，进程使用 session 的环境变量与工作目录
+func startWorker(envDir string, seccomp *SeccompProfile, session *Task) (*worker, error) {
+	dir, err := os.MkdirTemp("", "python_worker_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worker directory: %v", err)
+	}
+	script := filepath.Join(dir, workerFileName)
+	if err := os.WriteFile(script, workerScript, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write worker script: %v", err)
+	}
+	if err := writeHelper(dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	requestR, requestW, err := os.Pipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create worker pipe: %v", err)
+	}
+	responseR, responseW, err := os.Pipe()
+	if err != nil {
+		requestR.Close()
+		requestW.Close()
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create worker pipe: %v", err)
+	}
+
+	args := []string{script}
+	if session != nil {
+		args = append(args, "--session")
+	}
+	w := &worker{
+		cmd:       exec.Command(filepath.Join(envDir, "bin", "python"), args...),
+		dir:       dir,
+		requests:  requestW,
+		responses: responseR,
+		reader:    bufio.NewReader(responseR),
+		stderr:    &tailBuffer{max: workerStderrTail},
+		exited:    make(chan struct{}),
+	}
+	// worker 自身不继承当前进程的环境变量，每个任务的环境变量随请求发送
+	w.cmd.Env = (&Task{EnvInherit: InheritNone}).environ(envDir)
+	if session != nil {
+		// 会话的代码单元共享进程的环境变量与当前目录
+		w.cmd.Env = session.environ(envDir)
+		w.cmd.Dir = session.scriptDir("")
+	}
+	w.cmd.ExtraFiles = []*os.File{requestR, responseW}
+	w.cmd.Stderr = w.stderr
+	setProcessGroup(w.cmd)
+	if seccomp != nil {
+		if err := applySeccomp(w.cmd, seccomp); err != nil {
+			err = fmt.Errorf("failed to apply seccomp profile: %w", err)
+			w.cmd = nil
+			requestR.Close()
+			responseW.Close()
+			w.close()
+			return nil, err
+		}
+	}
+
+	err = w.cmd.Start()
+	requestR.Close()
+	responseW.Close()
+	if err != nil {
+		w.cmd = nil
+		w.close()
+		return nil, fmt.Errorf("failed to start python worker: %v", err)
+	}
+	go func() {
+		w.cmd.Wait()
+		close(w.exited)
+	}()
+	return w, nil
+}
+
+// run 将请求发送给 worker 并等待结果，结果之前收到的输出交给 onOutput
+// ctx 结束时先向 worker 的进程组发送 SIGTERM，超过宽限期后发送 SIGKILL，此后该 worker 不能再使用
+func (w *worker) run(ctx context.Context, req workerRequest, grace time.Duration, onOutput func(stream, data string)) (*workerResponse, error) {
+	w.tasks++
+	type reply struct {
+		resp *workerResponse
+		err  error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		resp, err := w.call(req, onOutput)
+		done <- reply{resp, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			// 读取失败通常意味着 worker 已退出，等待其退出状态
+			w.kill(0)
+			return nil, fmt.Errorf("python worker exited: %v", r.err)
+		}
+		return r.resp, nil
+	case <-ctx.Done():
+		w.kill(grace)
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+// call 发送一条请求并读取响应，响应之前的输出消息交给 onOutput
+func (w *worker) call(req workerRequest, onOutput func(stream, data string)) (*workerResponse, error) {
+	if err := w.send(req); err != nil {
+		return nil, err
+	}
+	for {
+		resp := &workerResponse{}
+		if err := w.receive(resp); err != nil {
+			return nil, err
+		}
+		if resp.Stream == "" {
+			return resp, nil
+		}
+		if onOutput != nil {
+			onOutput(resp.Stream, resp.Data)
+		}
+	}
+}
+
+// send 发送一条请求
+func (w *worker) send(req workerRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	_, err = w.requests.Write(append(header, data...))
+	return err
+}
+
+// receive 读取一条消息
+func (w *worker) receive(resp *workerResponse) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(w.reader, header); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxWorkerMessage {
+		return fmt.Errorf("response too large: %d bytes", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(w.reader, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, resp)
+}
+
+// kill 终止 worker 的整个进程组并等待其退出，grace 大于0时先发送 SIGTERM
+func (w *worker) kill(grace time.Duration) {
+	w.once.Do(func() {
+		if w.cmd != nil && w.cmd.Process != nil {
+			pgid := w.cmd.Process.Pid
+			if grace > 0 {
+				signalProcessGroup(pgid, syscall.SIGTERM)
+				select {
+				case <-w.exited:
+				case <-time.After(grace):
+				}
+			}
+			signalProcessGroup(pgid, syscall.SIGKILL)
+			<-w.exited
+		}
+		w.close()
+	})
+}
+
+// close 关闭管道并删除 worker 目录
+func (w *worker) close() {
+	w.requests.Close()
+	w.responses.Close()
+	os.RemoveAll(w.dir)
+}
+
+// tailBuffer 只保留最后 max 个字节的缓冲区
+type tailBuffer struct {
+	max  int
+	data []byte
+	mu   sync.Mutex
+}
+
+// Write 实现 io.Writer
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = append([]byte(nil), b.data[len(b.data)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// String 返回缓冲区的内容
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
@@ -0,0 +1,100 @@
+package pyExecuter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// DefaultKillGracePeriod 发送 SIGTERM 后等待进程自行退出的默认时长
+const DefaultKillGracePeriod = 5 * time.Second
+
+// reapTimeout 清理残留后代进程时等待其退出的最长时间
+const reapTimeout = 2 * time.Second
+
+// configureGroupKill 让命令在独立的进程组中运行，并配置 Context 结束时的终止方式：
+// 先向整个进程组发送 SIGTERM，超过宽限期仍未退出时发送 SIGKILL
+// 返回的 reap 函数需在 Wait 返回后调用，它会终止并确认清理所有残留的后代进程。
+// 输出应通过 newOutputPipe 连接，Wait 在子进程退出时即返回，不等待仍持有输出管道的后代进程
+func configureGroupKill(cmd *exec.Cmd, grace time.Duration) (reap func() error) {
+	if grace <= 0 {
+		grace = DefaultKillGracePeriod
+	}
+	setProcessGroup(cmd)
+
+	exited := make(chan struct{})
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		signalProcessGroup(pgid, syscall.SIGTERM)
+		go func() {
+			select {
+			case <-time.After(grace):
+				signalProcessGroup(pgid, syscall.SIGKILL)
+			case <-exited:
+			}
+		}()
+		return nil
+	}
+	// 取消后子进程超过宽限期仍未退出时由 Wait 强制终止
+	cmd.WaitDelay = grace + time.Second
+
+	return func() error {
+		close(exited)
+		if cmd.Process == nil {
+			return nil
+		}
+		pgid := cmd.Process.Pid
+		if !processGroupAlive(pgid) {
+			return nil
+		}
+		signalProcessGroup(pgid, syscall.SIGKILL)
+		deadline := time.Now().Add(reapTimeout)
+		for processGroupAlive(pgid) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("process group %d still has live descendants", pgid)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return nil
+	}
+}
+
+// outputPipe 连接子进程输出的管道，读端由独立的 goroutine 复制到目标 writer
+// 子进程直接持有写端文件，exec.Cmd 不会为其创建复制 goroutine，Wait 不受后代进程影响
+type outputPipe struct {
+	r, w *os.File
+	done chan struct{}
+}
+
+// newOutputPipe 创建复制到 dst 的输出管道，写端 w 用作 cmd.Stdout 或 cmd.Stderr
+func newOutputPipe(dst io.Writer) (*outputPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output pipe: %v", err)
+	}
+	p := &outputPipe{r: r, w: w, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		io.Copy(dst, r)
+	}()
+	return p, nil
+}
+
+// closeWriter 关闭父进程持有的写端，子进程启动后或启动失败时调用
+func (p *outputPipe) closeWriter() {
+	p.w.Close()
+}
+
+// wait 在 reap 之后调用，等待管道中剩余的输出复制完毕，后代进程未能清理时最多等待 reapTimeout
+func (p *outputPipe) wait() {
+	p.w.Close()
+	select {
+	case <-p.done:
+	case <-time.After(reapTimeout):
+	}
+	p.r.Close()
+	<-p.done
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package pyExecuter
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 当前平台不支持进程组，不做任何处理
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup 当前平台只能直接终止子进程本身
+func signalProcessGroup(pgid int, sig syscall.Signal) error {
+	process, err := os.FindProcess(pgid)
+	if err != nil {
+		return nil
+	}
+	return process.Kill()
+}
+
+// processGroupAlive 当前平台无法探测后代进程
+func processGroupAlive(pgid int) bool {
+	return false
+}
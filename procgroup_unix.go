@@ -0,0 +1,57 @@
+//go:build unix
+
+package pyExecuter
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup 让子进程在独立的进程组中运行，便于终止其所有后代进程
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup 向整个进程组发送信号
+func signalProcessGroup(pgid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pgid, sig)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// processGroupAlive 判断进程组中是否还有存活（非僵尸）的进程
+func processGroupAlive(pgid int) bool {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		// 没有 /proc 时退化为信号探测，僵尸进程也会被视为存活
+		return syscall.Kill(-pgid, 0) != syscall.ESRCH
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// 格式为 "pid (comm) state ppid pgrp ..."，comm 中可能包含空格
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 3 || fields[0] == "Z" || fields[0] == "X" {
+			continue
+		}
+		if fields[2] == strconv.Itoa(pgid) {
+			return true
+		}
+	}
+	return false
+}
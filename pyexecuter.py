@@ -0,0 +1,113 @@
+"""pyExecuter 注入到脚本目录中的辅助模块。
+
+用法:
+    import pyexecuter
+    data = pyexecuter.payload()
+    with open(pyexecuter.input_path("data.csv")) as f:
+        ...
+    pyexecuter.set_result({"answer": 42})
+"""
+import json
+import os
+import traceback
+
+__all__ = ["set_result", "payload", "input_path"]
+
+
+def set_result(value):
+    """将 value 以 JSON 写入结果通道，与标准输出分开返回给调用方。
+
+    多次调用时以最后一次为准。value 必须可以被 json.dumps 序列化，
+    且不能包含 NaN 或 Infinity（它们不是合法的 JSON，调用方无法解码）。
+    """
+    path = os.environ.get("PYEXECUTER_RESULT_PATH")
+    if not path:
+        raise RuntimeError("pyexecuter result channel is not available")
+    try:
+        data = json.dumps(value, allow_nan=False)
+    except ValueError as e:
+        raise ValueError("pyexecuter result is not valid JSON: %s" % e) from None
+    tmp = path + ".tmp"
+    with open(tmp, "w") as f:
+        f.write(data)
+    os.replace(tmp, path)
+
+
+def payload():
+    """返回任务的 JSON 负载，任务没有提供负载时返回 None。"""
+    path = os.environ.get("PYEXECUTER_PAYLOAD_PATH")
+    if not path or not os.path.exists(path):
+        return None
+    with open(path, encoding="utf-8") as f:
+        return json.load(f)
+
+
+def input_path(name):
+    """返回任务输入文件 name 的绝对路径。"""
+    directory = os.environ.get("PYEXECUTER_INPUT_DIR")
+    if not directory:
+        raise RuntimeError("pyexecuter inputs are not available")
+    return os.path.join(directory, name)
+
+
+def _strip_traceback(tb, script):
+    """丢弃 script 之前的帧（启动脚本、runpy 等），找不到脚本帧时返回 None。
+
+    script 为 None 时丢弃开头属于启动脚本与 runpy 的帧。
+    """
+    if script is None:
+        while tb is not None and _is_internal(tb.tb_frame.f_code.co_filename):
+            tb = tb.tb_next
+        return tb
+    while tb is not None and tb.tb_frame.f_code.co_filename != script:
+        tb = tb.tb_next
+    return tb
+
+
+def _is_internal(filename):
+    """判断帧是否属于执行器或 runpy。"""
+    import runpy
+
+    return (
+        os.path.basename(filename) == "_pyexecuter_bootstrap.py"
+        or filename == runpy.__file__
+        or filename.startswith("<frozen runpy")
+    )
+
+
+def _describe_exception(exc, tb, depth=0):
+    """将异常转换为可 JSON 序列化的描述，包括由 raise ... from 或处理过程中引发的原因异常。"""
+    cls = type(exc)
+    name = cls.__qualname__
+    if cls.__module__ not in ("builtins", "__main__"):
+        name = cls.__module__ + "." + name
+    frames = [
+        {"file": f.filename, "line": f.lineno or 0, "function": f.name, "source": f.line or ""}
+        for f in traceback.extract_tb(tb)
+    ]
+    if isinstance(exc, SyntaxError) and exc.filename:
+        # 语法错误发生在编译阶段，没有对应的执行帧
+        frames.append(
+            {"file": exc.filename, "line": exc.lineno or 0, "function": "<module>", "source": (exc.text or "").strip()}
+        )
+    info = {
+        "type": name,
+        "message": str(exc),
+        "frames": frames,
+        "traceback": "".join(traceback.format_exception(cls, exc, tb)),
+    }
+    cause = exc.__cause__
+    if cause is None and not exc.__suppress_context__:
+        cause = exc.__context__
+    if cause is not None and depth < 8:
+        info["cause"] = _describe_exception(cause, cause.__traceback__, depth + 1)
+    return info
+
+
+def _report_exception(exc, tb, path):
+    """将未捕获异常的描述写入 path，供执行器解析（内部使用）。"""
+    try:
+        with open(path, "w") as f:
+            json.dump(_describe_exception(exc, tb), f)
+    except Exception:
+        pass
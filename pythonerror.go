@@ -0,0 +1,107 @@
+package pyExecuter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScriptFileName 提交的脚本在 PythonError 帧中使用的文件名
+const ScriptFileName = "<script>"
+
+// errorPathEnv 向启动脚本传递异常信息文件路径的环境变量
+const errorPathEnv = "PYEXECUTER_ERROR_PATH"
+
+// errorFileName 异常信息文件在临时目录中的文件名
+const errorFileName = "_pyexecuter_error.json"
+
+// PythonFrame Python调用栈中的一帧
+type PythonFrame struct {
+	File     string `json:"file"`     // 文件名，提交的脚本为 ScriptFileName
+	Line     int    `json:"line"`     // 行号
+	Function string `json:"function"` // 函数名，模块级代码为 "<module>"
+	Source   string `json:"source"`   // 该行的源码
+}
+
+// PythonError 脚本抛出的未捕获Python异常，可通过 errors.As 从执行错误中取得
+type PythonError struct {
+	Type      string        `json:"type"`      // 异常类型，内置异常不带模块名，例如 "ValueError"
+	Message   string        `json:"message"`   // 异常消息
+	Frames    []PythonFrame `json:"frames"`    // 调用栈，由外到内，不含执行器自身的帧
+	Traceback string        `json:"traceback"` // 格式化后的完整回溯
+	Cause     *PythonError  `json:"cause"`     // 通过 raise ... from 或在处理异常时引发的原因异常
+}
+
+// Error 实现 error 接口
+func (e *PythonError) Error() string {
+	if e.Message == "" {
+		return e.Type
+	}
+	return e.Type + ": " + e.Message
+}
+
+// Unwrap 返回原因异常
+func (e *PythonError) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+	return e.Cause
+}
+
+// ScriptFrame 返回异常发生处最内层的脚本帧
+func (e *PythonError) ScriptFrame() (PythonFrame, bool) {
+	for i := len(e.Frames) - 1; i >= 0; i-- {
+		if e.Frames[i].File == ScriptFileName {
+			return e.Frames[i], true
+		}
+	}
+	return PythonFrame{}, false
+}
+
+// errorPath 返回工作目录中异常信息文件的路径
+func errorPath(workDir string) string {
+	return filepath.Join(workDir, errorFileName)
+}
+
+// readPythonError 读取启动脚本写入的异常信息，没有异常或无法解析时返回 nil
+func readPythonError(path, scriptPath, script string) *PythonError {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parsePythonError(data, scriptPath, script)
+}
+
+// hideScriptPath 将输出中临时脚本文件的路径替换为 ScriptFileName，与 PythonError 中的帧一致
+func hideScriptPath(output, scriptPath string) string {
+	if scriptPath == "" {
+		return output
+	}
+	return strings.ReplaceAll(output, scriptPath, ScriptFileName)
+}
+
+// parsePythonError 解析异常信息，并将位于 scriptPath 的帧映射回提交的脚本，scriptPath 为空时不做映射
+func parsePythonError(data []byte, scriptPath, script string) *PythonError {
+	var pyErr PythonError
+	if err := json.Unmarshal(data, &pyErr); err != nil || pyErr.Type == "" {
+		return nil
+	}
+	lines := strings.Split(script, "\n")
+	for e := &pyErr; e != nil; e = e.Cause {
+		for i := range e.Frames {
+			frame := &e.Frames[i]
+			if scriptPath == "" || frame.File != scriptPath && frame.File != ScriptFileName {
+				continue
+			}
+			frame.File = ScriptFileName
+			if frame.Line >= 1 && frame.Line <= len(lines) {
+				frame.Source = strings.TrimSpace(lines[frame.Line-1])
+			}
+		}
+		if scriptPath != "" {
+			e.Traceback = strings.ReplaceAll(e.Traceback, `"`+scriptPath+`"`, `"`+ScriptFileName+`"`)
+		}
+	}
+	return &pyErr
+}
@@ -1,30 +1,85 @@
 package pyExecuter
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
 // PythonExecutor Python脚本执行的接口
 type PythonExecutor interface {
-	Execute(script string, args []string, timeout time.Duration) (string, error) // 执行Python脚本
-	SetupEnvironment(envName string) error                                       // 设置Python虚拟环境
+	Execute(script string, args []string, timeout time.Duration) (string, error)         // 执行Python脚本
+	Run(ctx context.Context, task *Task) (*ExecutionResult, error)                       // 执行任务并返回结构化结果
+	Stream(ctx context.Context, task *Task, out *OutputStream) (*ExecutionResult, error) // 执行任务并实时分发输出
+	SetupEnvironment(envName string) error                                               // 设置Python虚拟环境
+}
+
+// ExecutionResult 描述一次Python脚本执行的结构化结果
+type ExecutionResult struct {
+	Stdout   string         // 标准输出，超过上限时按 OutputLimits 的策略截断
+	Stderr   string         // 标准错误输出，超过上限时按 OutputLimits 的策略截断
+	ExitCode int            // 进程退出码（被信号终止时为-1）
+	Signal   syscall.Signal // 终止进程的信号（正常退出时为0）
+	WallTime time.Duration  // 实际耗时
+	CPUTime  time.Duration  // 用户态与内核态CPU时间之和
+	TimedOut bool           // 是否因超时而被终止
+
+	StdoutBytes     int64 // 脚本实际写入标准输出的字节数
+	StderrBytes     int64 // 脚本实际写入标准错误的字节数
+	StdoutTruncated bool  // 标准输出是否因超过上限而被截断
+	StderrTruncated bool  // 标准错误是否因超过上限而被截断
+
+	PythonVersion string // 实际使用的Python解释器版本
+	LimitExceeded string // 导致任务终止的资源限制（LimitMemory、LimitCPU 等），未触发时为空
+
+	SeccompViolation bool   // 是否因调用被 seccomp 规则拒绝的系统调用而被终止
+	SeccompSyscall   string // 被拒绝的系统调用名称，仅 trap 动作可以确定，否则为空
+
+	Exception *PythonError // 脚本抛出的未捕获异常，没有异常时为 nil
+
+	Value    interface{}     // 脚本通过 pyexecuter.set_result 返回的值，按 JSON 解码
+	RawValue json.RawMessage // 返回值的原始 JSON，可解码为具体类型；脚本未返回值时为空
+
+	Artifacts []Artifact // 按 Task.Outputs 收集的产物
 }
 
 // SecurePythonExecutor 实现了PythonExecutor接口，具有虚拟环境管理和安全机制
 type SecurePythonExecutor struct {
-	Environment string
+	Environment string       // 通过 SetupEnvironment 设置的虚拟环境目录
+	Venvs       *VenvManager // 可复用的虚拟环境管理器，Environment 为空时使用
+	Pip         PipOptions   // 安装任务依赖时使用的 pip 配置
+
+	Interpreter  InterpreterSelector  // 默认的解释器选择条件，任务未指定时使用
+	Interpreters *InterpreterRegistry // 解释器注册表，为 nil 时使用共享的默认实例
+
+	KillGracePeriod time.Duration // 超时或取消时，SIGTERM 与 SIGKILL 之间的宽限期，默认 DefaultKillGracePeriod
+	CgroupParent    string        // 创建任务 cgroup 的父目录（不包含进程、委派给执行器的 cgroup），为空时只能使用根 cgroup
+
+	Seccomp *SeccompProfile // 执行Python前安装的 seccomp 过滤规则，为 nil 时不启用（仅支持 Linux，程序须在 main 的开始处调用 RunLauncher）
+
+	InputLimits    InputLimits    // 任务输入的大小限制，零值字段使用默认值
+	Artifacts      ArtifactStore  // 保存任务产物的存储，任务声明了 Outputs 时必须设置
+	ArtifactLimits ArtifactLimits // 产物收集的限制，零值字段使用默认值
+	OutputLimits   *OutputLimits  // 任务未设置 OutputLimits 时使用的输出上限，为 nil 时使用 DefaultOutputLimits
 }
 
 // SetupEnvironment 设置Python虚拟环境
 func (p *SecurePythonExecutor) SetupEnvironment(envName string) error {
-	// 创建虚拟环境
-	cmd := exec.Command("python", "-m", "venv", envName)
+	interp, err := p.ResolveInterpreter(p.Interpreter)
+	if err != nil {
+		return err
+	}
+
+	// 使用选定的解释器创建虚拟环境
+	cmd := exec.Command(interp.Path, "-m", "venv", envName)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to create virtual environment: %v", err)
 	}
@@ -34,44 +89,336 @@ func (p *SecurePythonExecutor) SetupEnvironment(envName string) error {
 }
 
 // Execute 执行Python脚本，返回输出或者错误
+// 它是 Run 的简单封装，输出为标准输出与标准错误的拼接
 func (p *SecurePythonExecutor) Execute(script string, args []string, timeout time.Duration) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	res, err := p.Run(context.Background(), &Task{Script: script, Args: args, Timeout: timeout})
+	if err != nil {
+		return "", err
+	}
+	return res.Stdout + res.Stderr, nil
+}
+
+// Run 执行任务中的Python脚本，返回结构化的执行结果
+// 只要进程成功启动，即使执行失败或超时也会返回结果，便于排查错误
+func (p *SecurePythonExecutor) Run(ctx context.Context, task *Task) (*ExecutionResult, error) {
+	return p.Stream(ctx, task, nil)
+}
+
+// Stream 执行任务中的Python脚本，并在运行过程中将输出分发到 out
+// out 为 nil 时等同于 Run
+func (p *SecurePythonExecutor) Stream(ctx context.Context, task *Task, out *OutputStream) (*ExecutionResult, error) {
+	return p.stream(ctx, task, out, nil)
+}
+
+// commandHook 在Python进程启动前对命令做额外配置（例如放入沙箱）
+// envDir 为虚拟环境目录，workDir 为存放脚本的临时工作目录
+type commandHook func(cmd *exec.Cmd, task *Task, envDir, workDir string) error
+
+// stream 执行任务的通用实现，prepare 不为 nil 时在启动进程前调用
+func (p *SecurePythonExecutor) stream(ctx context.Context, task *Task, out *OutputStream, prepare commandHook) (*ExecutionResult, error) {
+	if err := p.validateTask(task); err != nil {
+		return nil, err
+	}
+	// 登记任务的敏感环境变量，执行结束后保留到调用 ReleaseTaskSecrets
+	defer secrets.register(task)()
+	envDir, version, release, err := p.acquireEnvironment(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
 
 	// 创建一个临时文件来存储脚本
-	tmpFile, err := createTempPythonFile(script)
+	tmpFile, err := createTempPythonFile(task.Script)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %v", err)
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
 	}
 	defer removeTempPythonFile(tmpFile)
 
-	// 准备命令
-	pythonPath := filepath.Join(p.Environment, "bin", "python")
-	cmdArgs := append([]string{tmpFile}, args...)
-	cmd := exec.CommandContext(ctx, pythonPath, cmdArgs...)
+	workDir := filepath.Dir(tmpFile)
+
+	bootstrap, err := writeBootstrap(workDir)
+	if err != nil {
+		return nil, err
+	}
+	stdinPath, err := task.Input.materialize(workDir, p.InputLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, target, err := task.sourceTarget(tmpFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve script source: %v", err)
+	}
+	modulePath, err := task.pythonPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve python path: %v", err)
+	}
+
+	// 准备命令，脚本通过启动脚本运行，以便在执行前设置资源限制
+	pythonPath := filepath.Join(envDir, "bin", "python")
+	cmdArgs := append([]string{bootstrap, target}, task.Args...)
+	// 输出超过上限且策略为 OutputKill 时通过 cmdCtx 终止进程，与任务的取消区分
+	cmdCtx, cancelCmd := context.WithCancel(ctx)
+	defer cancelCmd()
+	cmd := exec.CommandContext(cmdCtx, pythonPath, cmdArgs...)
+	scriptDir := task.scriptDir(workDir)
+	cmd.Dir = scriptDir
+	reap := configureGroupKill(cmd, p.KillGracePeriod)
 
 	// 设置环境变量
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("VIRTUAL_ENV=%s", p.Environment),
-		fmt.Sprintf("PATH=%s:%s", filepath.Join(p.Environment, "bin"), os.Getenv("PATH")),
+	cmd.Env = append(task.environ(envDir),
+		resultPathEnv+"="+resultPath(workDir),
+		errorPathEnv+"="+errorPath(workDir),
+		inputDirEnv+"="+workDir,
+		payloadPathEnv+"="+payloadPath(workDir),
+		sourceEnv+"="+kind,
+		pythonPathEnv+"="+strings.Join(modulePath, string(os.PathListSeparator)),
 	)
+	if rlimits := task.Limits.rlimitsEnv(); rlimits != "" {
+		cmd.Env = append(cmd.Env, "PYEXECUTER_RLIMITS="+rlimits)
+	}
+
+	// 通过临时 cgroup 限制内存与进程数，无法强制执行时不运行任务
+	cgroup, err := newTaskCgroup(p.CgroupParent, task.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLimitUnenforceable, err)
+	}
+	cgroup.attach(cmd)
+	defer cgroup.remove()
+
+	if prepare != nil {
+		// 子进程启动时已继承附加的文件描述符，父进程中的副本随后关闭
+		defer func() {
+			for _, f := range cmd.ExtraFiles {
+				f.Close()
+			}
+		}()
+		if err := prepare(cmd, task, envDir, workDir); err != nil {
+			return nil, err
+		}
+	}
+	if p.Seccomp != nil {
+		if err := applySeccomp(cmd, p.Seccomp); err != nil {
+			return nil, fmt.Errorf("failed to apply seccomp profile: %w", err)
+		}
+	}
 
-	// 设置输出缓冲
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
+	if stdinPath != "" {
+		stdin, err := os.Open(stdinPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open stdin: %v", err)
+		}
+		defer stdin.Close()
+		cmd.Stdin = stdin
+	}
+
+	// 分别收集标准输出和标准错误
+	limits := task.outputLimits(p.OutputLimits)
+	stdout := newCappedBuffer(limits.StdoutBytes, limits.Policy, cancelCmd)
+	stderr := newCappedBuffer(limits.StderrBytes, limits.Policy, cancelCmd)
+	var stdoutDst, stderrDst io.Writer = stdout, stderr
+	if out != nil {
+		stdoutWriter := out.writer(task.ID, StreamStdout)
+		stderrWriter := out.writer(task.ID, StreamStderr)
+		defer stdoutWriter.Flush()
+		defer stderrWriter.Flush()
+		stdoutDst = io.MultiWriter(stdout, stdoutWriter)
+		stderrDst = io.MultiWriter(stderr, stderrWriter)
+	}
+	stdoutPipe, err := newOutputPipe(stdoutDst)
+	if err != nil {
+		return nil, err
+	}
+	stderrPipe, err := newOutputPipe(stderrDst)
+	if err != nil {
+		stdoutPipe.wait()
+		return nil, err
+	}
+	cmd.Stdout = stdoutPipe.w
+	cmd.Stderr = stderrPipe.w
 
 	// 执行命令
-	err = cmd.Run()
+	start := time.Now()
+	err = cmd.Start()
+	stdoutPipe.closeWriter()
+	stderrPipe.closeWriter()
+	if err != nil {
+		stdoutPipe.wait()
+		stderrPipe.wait()
+		return nil, fmt.Errorf("failed to start python: %v", err)
+	}
+	err = cmd.Wait()
+	// 脚本退出后立即终止残留的后代进程，它们持有的输出管道随之关闭
+	reapErr := reap()
+	stdoutPipe.wait()
+	stderrPipe.wait()
 
+	res := &ExecutionResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: -1,
+		WallTime: time.Since(start),
+
+		StdoutBytes:     stdout.Total(),
+		StderrBytes:     stderr.Total(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+
+		PythonVersion: version,
+	}
+	if state := cmd.ProcessState; state != nil {
+		res.ExitCode = state.ExitCode()
+		res.CPUTime = state.UserTime() + state.SystemTime()
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			res.Signal = status.Signal()
+		}
+	}
+
+	if err != nil && p.Seccomp != nil {
+		detectSeccompViolation(res)
+	}
+	if err != nil && !res.SeccompViolation {
+		res.LimitExceeded = detectLimitExceeded(task.Limits, res, cgroup.events())
+	}
+	if err != nil && ctx.Err() == nil && cmdCtx.Err() != nil {
+		res.LimitExceeded = LimitOutput
+	}
+	if kind == sourceScript {
+		res.Stderr = hideScriptPath(res.Stderr, tmpFile)
+	}
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("execution timed out after %v", timeout)
+		if kind == sourceScript {
+			res.Exception = readPythonError(errorPath(workDir), tmpFile, task.Script)
+		} else {
+			// 脚本文件与模块中的帧保留其真实路径
+			res.Exception = readPythonError(errorPath(workDir), "", "")
 		}
-		return "", fmt.Errorf("execution failed: %v", err)
 	}
+	decodeErr := readResult(res, resultPath(workDir))
+	artifacts, artifactErr := collectArtifacts(context.Background(), p.Artifacts, p.ArtifactLimits, task, scriptDir)
+	res.Artifacts = artifacts
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.TimedOut = true
+		return res, fmt.Errorf("execution timed out after %v", task.Timeout)
+	case ctx.Err() != nil:
+		return res, fmt.Errorf("execution cancelled: %w", ctx.Err())
+	case res.SeccompViolation && res.SeccompSyscall != "":
+		return res, fmt.Errorf("execution killed by seccomp: syscall %s denied: %w", res.SeccompSyscall, err)
+	case res.SeccompViolation:
+		return res, fmt.Errorf("execution killed by seccomp: %w", err)
+	case res.LimitExceeded != "":
+		return res, fmt.Errorf("execution exceeded %s limit: %w", res.LimitExceeded, err)
+	case res.Exception != nil:
+		return res, fmt.Errorf("execution failed: %w", res.Exception)
+	case err != nil:
+		return res, fmt.Errorf("execution failed: %w", err)
+	case reapErr != nil:
+		return res, fmt.Errorf("failed to clean up subprocesses: %v", reapErr)
+	case decodeErr != nil:
+		return res, decodeErr
+	case artifactErr != nil:
+		return res, artifactErr
+	}
+	return res, nil
+}
 
-	return out.String(), nil
+// validateTask 在准备环境之前校验任务的脚本来源、环境变量、输入与产物声明，避免为无效的任务安装依赖
+func (p *SecurePythonExecutor) validateTask(task *Task) error {
+	if err := task.validateSource(); err != nil {
+		return err
+	}
+	if err := task.validateEnv(); err != nil {
+		return err
+	}
+	if err := task.OutputLimits.validate(); err != nil {
+		return err
+	}
+	if err := task.Input.validate(p.InputLimits); err != nil {
+		return err
+	}
+	if len(task.Outputs) > 0 && p.Artifacts == nil {
+		return errors.New("task declares outputs but no artifact store is configured")
+	}
+	return validateOutputs(task.Outputs)
+}
+
+// errSharedEnvDependencies 使用固定环境时任务声明了依赖
+// 固定环境被所有任务共享，同时执行的任务会并发向同一环境安装依赖，且一个任务的依赖会留给之后的任务
+var errSharedEnvDependencies = errors.New("task dependencies require Venvs: the fixed environment is shared between tasks")
+
+// ResolveInterpreter 按选择条件解析解释器，sel 为空时使用 PATH 中的 "python"
+func (p *SecurePythonExecutor) ResolveInterpreter(sel InterpreterSelector) (Interpreter, error) {
+	registry := p.Interpreters
+	if registry == nil {
+		registry = defaultInterpreters
+	}
+	return registry.Resolve(sel)
+}
+
+// validateEnvironment 校验任务能否在执行器的环境中执行，任务的选择条件为空时使用执行器的默认条件
+// 使用固定环境（Environment 已设置或没有 Venvs）时任务的选择条件不生效，但不能声明依赖
+func (p *SecurePythonExecutor) validateEnvironment(task *Task) error {
+	if p.Environment != "" || p.Venvs == nil {
+		if task.Dependencies.normalized() != "" {
+			return errSharedEnvDependencies
+		}
+		return nil
+	}
+	sel := task.Interpreter
+	if sel.IsZero() {
+		sel = p.Interpreter
+	}
+	_, err := p.ResolveInterpreter(sel)
+	return err
+}
+
+// acquireEnvironment 返回任务使用的虚拟环境目录、解释器版本以及归还函数
+// 已通过 SetupEnvironment 设置环境时直接使用（此时任务不能声明依赖），否则从 Venvs 获取由选定解释器创建的可复用环境
+// 任务声明的依赖会在创建环境时安装，安装失败时返回 *DependencyError
+func (p *SecurePythonExecutor) acquireEnvironment(ctx context.Context, task *Task) (string, string, func(), error) {
+	requirements := task.Dependencies.normalized()
+	if p.Environment != "" || p.Venvs == nil {
+		if requirements != "" {
+			return "", "", nil, errSharedEnvDependencies
+		}
+		version, _ := p.ResolveInterpreter(InterpreterSelector{Path: filepath.Join(p.Environment, "bin", "python")})
+		return p.Environment, version.Version, func() {}, nil
+	}
+
+	sel := task.Interpreter
+	if sel.IsZero() {
+		sel = p.Interpreter
+	}
+	interp, err := p.ResolveInterpreter(sel)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	// 没有依赖时不调用 pip，安装选项不影响环境内容
+	var options string
+	if requirements != "" {
+		options = p.Pip.cacheKey()
+	}
+	venv, err := p.Venvs.Acquire(ctx, EnvSpec{
+		Interpreter:  interp.Path,
+		Dependencies: requirements,
+		Options:      options,
+		Provision: func(ctx context.Context, envDir string) error {
+			return installDependencies(ctx, envDir, task.Dependencies, p.Pip)
+		},
+	})
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to setup environment: %w", err)
+	}
+	return venv.Dir, venv.PythonVersion, venv.Release, nil
 }
 
 // createTempPythonFile 创建一个临时的Python文件
@@ -0,0 +1,99 @@
+package pyExecuter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 触发的资源限制类型
+const (
+	LimitMemory    = "memory"     // 内存（地址空间或RSS）
+	LimitCPU       = "cpu"        // CPU 时间
+	LimitOpenFiles = "open_files" // 打开的文件数
+	LimitProcesses = "processes"  // 进程数
+	LimitFileSize  = "file_size"  // 输出文件大小
+)
+
+// ErrLimitUnenforceable 任务要求的资源限制无法强制执行（例如 cgroup v2 不可用），任务不会被执行
+var ErrLimitUnenforceable = errors.New("resource limits cannot be enforced")
+
+// ResourceLimits 任务的资源限制，零值表示不限制
+// 其余限制通过子进程的 rlimit 实现；MemoryBytes 与 Processes 只能通过临时 cgroup（cgroup v2）强制执行，
+// cgroup 不可用时设置了这两项的任务返回 ErrLimitUnenforceable
+type ResourceLimits struct {
+	AddressSpaceBytes uint64        // 最大虚拟地址空间（RLIMIT_AS）
+	MemoryBytes       uint64        // 最大常驻内存（cgroup 的 memory.max）
+	CPUTime           time.Duration // 最大CPU时间（RLIMIT_CPU），按秒向上取整
+	OpenFiles         uint64        // 最大打开文件数（RLIMIT_NOFILE）
+	// Processes 任务最多同时存在的进程数（cgroup 的 pids.max）
+	// 不使用 RLIMIT_NPROC：它统计的是同一真实 UID 的全部进程，而不是当前任务的进程
+	Processes     uint64
+	FileSizeBytes uint64 // 可写文件的最大大小（RLIMIT_FSIZE）
+}
+
+// rlimitsEnv 返回传递给启动脚本的 rlimit 配置
+func (l *ResourceLimits) rlimitsEnv() string {
+	if l == nil {
+		return ""
+	}
+	rlimits := make(map[string][2]uint64)
+	if l.AddressSpaceBytes > 0 {
+		rlimits["RLIMIT_AS"] = [2]uint64{l.AddressSpaceBytes, l.AddressSpaceBytes}
+	}
+	if l.CPUTime > 0 {
+		seconds := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		// 软限制先触发 SIGXCPU，硬限制再触发 SIGKILL
+		rlimits["RLIMIT_CPU"] = [2]uint64{seconds, seconds + 1}
+	}
+	if l.OpenFiles > 0 {
+		rlimits["RLIMIT_NOFILE"] = [2]uint64{l.OpenFiles, l.OpenFiles}
+	}
+	if l.FileSizeBytes > 0 {
+		rlimits["RLIMIT_FSIZE"] = [2]uint64{l.FileSizeBytes, l.FileSizeBytes}
+	}
+	if len(rlimits) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(rlimits)
+	return string(data)
+}
+
+// detectLimitExceeded 根据退出信号、cgroup 事件与标准错误判断任务触发了哪项资源限制
+func detectLimitExceeded(l *ResourceLimits, res *ExecutionResult, events cgroupEvents) string {
+	if l == nil {
+		return ""
+	}
+	if limit := limitFromSignal(res.Signal); limit != "" {
+		return limit
+	}
+	switch {
+	case events.OOMKills > 0:
+		return LimitMemory
+	case events.PidsMax > 0:
+		return LimitProcesses
+	case l.CPUTime > 0 && res.Signal != 0 && res.CPUTime >= l.CPUTime:
+		return LimitCPU
+	}
+
+	// rlimit 导致的系统调用失败会以 Python 异常的形式出现在标准错误中
+	stderr := res.Stderr
+	switch {
+	case (l.AddressSpaceBytes > 0 || l.MemoryBytes > 0) && strings.Contains(stderr, "MemoryError"):
+		return LimitMemory
+	case l.OpenFiles > 0 && strings.Contains(stderr, "Too many open files"):
+		return LimitOpenFiles
+	case l.Processes > 0 && strings.Contains(stderr, "Resource temporarily unavailable"):
+		return LimitProcesses
+	case l.FileSizeBytes > 0 && strings.Contains(stderr, "File too large"):
+		return LimitFileSize
+	}
+	return ""
+}
+
+// cgroupEvents 任务的临时 cgroup 中记录的资源限制事件
+type cgroupEvents struct {
+	OOMKills int // 因超出 memory.max 被终止的进程数
+	PidsMax  int // 因达到 pids.max 而失败的 fork 次数
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package pyExecuter
+
+import "syscall"
+
+// limitFromSignal 当前平台没有资源限制相关的信号
+func limitFromSignal(sig syscall.Signal) string {
+	return ""
+}
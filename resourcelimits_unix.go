@@ -0,0 +1,16 @@
+//go:build unix
+
+package pyExecuter
+
+import "syscall"
+
+// limitFromSignal 根据终止信号判断触发的资源限制
+func limitFromSignal(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGXCPU:
+		return LimitCPU
+	case syscall.SIGXFSZ:
+		return LimitFileSize
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package pyExecuter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultMaxBypass 资源不足的任务默认最多被越过的次数
+const DefaultMaxBypass = 5
+
+// cpuEpsilon 累加小数核数时允许的误差
+const cpuEpsilon = 1e-9
+
+// Resources 任务声明的资源需求，或执行器可分配的资源容量
+// 作为容量时，零值的 CPU 或 MemoryMB 表示该维度不限制，Custom 中未列出的资源不可分配
+type Resources struct {
+	CPU      float64        // CPU 核数，可以是小数
+	MemoryMB uint64         // 内存（MB）
+	Custom   map[string]int // 自定义的具名资源及数量，例如 "gpu-license" 许可证
+}
+
+// validate 校验资源需求，capacity 不为 nil 时需求不能超过容量（内部方法）
+func (r *Resources) validate(capacity *Resources) error {
+	if r == nil {
+		return nil
+	}
+	// NaN 与任何值比较都为假，无穷大会在预留量中相减得到 NaN，两者都会破坏准入的计算
+	if r.CPU < 0 || math.IsNaN(r.CPU) || math.IsInf(r.CPU, 0) {
+		return fmt.Errorf("invalid cpu request %g", r.CPU)
+	}
+	names := make([]string, 0, len(r.Custom))
+	for name, n := range r.Custom {
+		if n < 0 {
+			return fmt.Errorf("invalid request %d for resource %q", n, name)
+		}
+		names = append(names, name)
+	}
+	if capacity == nil {
+		return nil
+	}
+
+	// 超过容量的任务永远无法执行，提交时即拒绝
+	if capacity.CPU > 0 && r.CPU > capacity.CPU+cpuEpsilon {
+		return fmt.Errorf("cpu request %g exceeds capacity %g", r.CPU, capacity.CPU)
+	}
+	if capacity.MemoryMB > 0 && r.MemoryMB > capacity.MemoryMB {
+		return fmt.Errorf("memory request %d MB exceeds capacity %d MB", r.MemoryMB, capacity.MemoryMB)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n := r.Custom[name]
+		if n == 0 {
+			continue
+		}
+		available, ok := capacity.Custom[name]
+		if !ok {
+			return fmt.Errorf("unknown resource %q", name)
+		}
+		if n > available {
+			return fmt.Errorf("request %d for resource %q exceeds capacity %d", n, name, available)
+		}
+	}
+	return nil
+}
+
+// fits 返回在已预留 reserved 的情况下，容量 capacity 的剩余部分能否容纳需求 r（内部方法）
+func (r *Resources) fits(capacity, reserved *Resources) bool {
+	if r == nil || capacity == nil {
+		return true
+	}
+	if capacity.CPU > 0 && reserved.CPU+r.CPU > capacity.CPU+cpuEpsilon {
+		return false
+	}
+	if capacity.MemoryMB > 0 && reserved.MemoryMB+r.MemoryMB > capacity.MemoryMB {
+		return false
+	}
+	for name, n := range r.Custom {
+		if n > 0 && reserved.Custom[name]+n > capacity.Custom[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// add 预留资源 other（内部方法）
+func (r *Resources) add(other *Resources) {
+	if other == nil {
+		return
+	}
+	r.CPU += other.CPU
+	r.MemoryMB += other.MemoryMB
+	for name, n := range other.Custom {
+		if r.Custom == nil {
+			r.Custom = make(map[string]int)
+		}
+		r.Custom[name] += n
+	}
+}
+
+// sub 归还 add 预留的资源 other（内部方法）
+func (r *Resources) sub(other *Resources) {
+	if other == nil {
+		return
+	}
+	r.CPU -= other.CPU
+	if r.CPU < cpuEpsilon {
+		r.CPU = 0
+	}
+	r.MemoryMB -= other.MemoryMB
+	for name, n := range other.Custom {
+		if r.Custom[name] -= n; r.Custom[name] == 0 {
+			delete(r.Custom, name)
+		}
+	}
+}
+
+// clone 返回资源的副本
+func (r *Resources) clone() *Resources {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	if r.Custom != nil {
+		c.Custom = make(map[string]int, len(r.Custom))
+		for name, n := range r.Custom {
+			c.Custom[name] = n
+		}
+	}
+	return &c
+}
+
+// takeLocked 按出队顺序取出第一个可以执行的任务并预留其资源，调用方须持有 e.mu（内部方法）
+// 资源不足的任务可以被队列中靠后的较小任务越过；越过次数达到 MaxBypass 后，
+// 它之后的任务都不再出队，直到它获得资源，避免大任务饿死。
+// 被 AdaptiveConcurrency 暂缓的内存密集型任务只是跳过，不阻挡其他任务
+func (e *GopoolExecutor) takeLocked() (*Task, *Resources) {
+	maxBypass := e.MaxBypass
+	if maxBypass <= 0 {
+		maxBypass = DefaultMaxBypass
+	}
+	var waiting []*Task
+	blocked := false
+	task := e.Queue.take(func(t *Task) bool {
+		if blocked || (e.memoryHold > 0 && t.memoryBytes() >= e.memoryHold) {
+			return false
+		}
+		if t.Resources.fits(e.Capacity, &e.reserved) {
+			return true
+		}
+		waiting = append(waiting, t)
+		blocked = t.bypassed >= maxBypass
+		return false
+	})
+	if task == nil {
+		return nil, nil
+	}
+
+	for _, t := range waiting {
+		t.bypassed++
+	}
+	task.bypassed = 0
+	// 预留任务出队时的需求副本，归还时与之一致
+	reservation := task.Resources.clone()
+	e.reserved.add(reservation)
+	return task, reservation
+}
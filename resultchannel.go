@@ -0,0 +1,71 @@
+package pyExecuter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// resultPathEnv 向脚本暴露结果文件路径的环境变量，由辅助模块 pyexecuter 使用
+const resultPathEnv = "PYEXECUTER_RESULT_PATH"
+
+// resultFileName 结果文件在临时目录中的文件名
+const resultFileName = "_pyexecuter_result.json"
+
+// MaxResultBytes 结果通道中数据的最大长度
+const MaxResultBytes = 64 << 20
+
+// ResultDecodeError 脚本写入结果通道的数据无法解析，与脚本执行失败区分
+type ResultDecodeError struct {
+	Data []byte // 原始数据，超出长度上限时为空
+	Err  error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *ResultDecodeError) Error() string {
+	return fmt.Sprintf("malformed result payload: %v", e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *ResultDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// resultPath 返回工作目录中结果文件的路径
+func resultPath(workDir string) string {
+	return filepath.Join(workDir, resultFileName)
+}
+
+// readResult 读取并解码结果文件，脚本未写入结果时不做任何处理
+func readResult(res *ExecutionResult, path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return &ResultDecodeError{Err: err}
+	}
+	if info.Size() > MaxResultBytes {
+		return &ResultDecodeError{Err: fmt.Errorf("payload of %d bytes exceeds %d bytes", info.Size(), MaxResultBytes)}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &ResultDecodeError{Err: err}
+	}
+	return decodeResult(res, data)
+}
+
+// decodeResult 解码结果数据，写入 res.Value 与 res.RawValue
+func decodeResult(res *ExecutionResult, data []byte) error {
+	if len(data) > MaxResultBytes {
+		return &ResultDecodeError{Err: fmt.Errorf("payload of %d bytes exceeds %d bytes", len(data), MaxResultBytes)}
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &ResultDecodeError{Data: data, Err: err}
+	}
+	res.Value = value
+	res.RawValue = json.RawMessage(data)
+	return nil
+}
@@ -0,0 +1,67 @@
+package pyExecuter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultSandboxUID 沙箱内执行脚本所用的默认用户与组（nobody）
+const DefaultSandboxUID = 65534
+
+// sandboxErrorPrefix 启动器构建沙箱失败时写入标准错误的前缀
+const sandboxErrorPrefix = "pyexecuter sandbox: "
+
+// DefaultSandboxReadOnlyPaths 默认以只读方式暴露给沙箱的系统路径，不存在的路径会被忽略
+var DefaultSandboxReadOnlyPaths = []string{
+	"/usr", "/lib", "/lib64", "/lib32", "/bin", "/sbin",
+	"/etc/ld.so.cache", "/etc/localtime", "/etc/alternatives",
+}
+
+// SandboxConfig 沙箱的配置
+type SandboxConfig struct {
+	Network       bool     // 是否保留主机网络，默认使用独立的网络命名空间（无网络）
+	ReadOnlyPaths []string // 额外以只读方式暴露的主机路径
+	UID           int      // 沙箱内运行脚本的用户，0 表示使用 DefaultSandboxUID；以 root 运行时同时是主机上的用户
+	GID           int      // 沙箱内运行脚本的组，0 表示使用 DefaultSandboxUID；以 root 运行时同时是主机上的组
+}
+
+// SandboxExecutor 在 Linux 命名空间沙箱中执行不受信任脚本的 PythonExecutor 实现
+// 脚本运行在独立的 user、mount、PID、network 与 IPC 命名空间中：
+// 只能以只读方式看到虚拟环境、解释器和系统库，只有临时工作目录可写，默认没有网络，且以非特权用户运行
+// 沙箱由重新执行当前程序得到的启动器构建，程序必须在 main 的开始处调用 RunLauncher
+type SandboxExecutor struct {
+	SecurePythonExecutor               // 环境管理、资源限制等沿用 SecurePythonExecutor 的实现
+	Sandbox              SandboxConfig // 沙箱配置
+}
+
+// NewSandboxExecutor 创建 SandboxExecutor 实例
+func NewSandboxExecutor(venvs *VenvManager, config SandboxConfig) *SandboxExecutor {
+	return &SandboxExecutor{
+		SecurePythonExecutor: SecurePythonExecutor{Venvs: venvs},
+		Sandbox:              config,
+	}
+}
+
+// Execute 在沙箱中执行Python脚本，返回标准输出与标准错误的拼接
+func (s *SandboxExecutor) Execute(script string, args []string, timeout time.Duration) (string, error) {
+	res, err := s.Run(context.Background(), &Task{Script: script, Args: args, Timeout: timeout})
+	if err != nil {
+		return "", err
+	}
+	return res.Stdout + res.Stderr, nil
+}
+
+// Run 在沙箱中执行任务，返回结构化的执行结果
+func (s *SandboxExecutor) Run(ctx context.Context, task *Task) (*ExecutionResult, error) {
+	return s.Stream(ctx, task, nil)
+}
+
+// Stream 在沙箱中执行任务，并在运行过程中将输出分发到 out
+func (s *SandboxExecutor) Stream(ctx context.Context, task *Task, out *OutputStream) (*ExecutionResult, error) {
+	res, err := s.stream(ctx, task, out, s.prepareCommand)
+	if reason, ok := launcherFailure(res, sandboxErrorPrefix); ok && err != nil {
+		return res, fmt.Errorf("failed to setup sandbox: %s", reason)
+	}
+	return res, err
+}
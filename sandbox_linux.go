@@ -0,0 +1,401 @@
+package pyExecuter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// sandboxSpecEnv 向启动器传递沙箱配置的环境变量
+const sandboxSpecEnv = "PYEXECUTER_SANDBOX"
+
+// sandboxSpec 启动器构建沙箱所需的配置
+type sandboxSpec struct {
+	Root     string        // 新根文件系统的挂载点
+	ReadOnly []sandboxBind // 以只读方式绑定到新根中相同路径的主机路径，父路径在前
+	Writable []sandboxBind // 以可写方式绑定到新根中相同路径的主机路径
+	WorkDir  string        // 脚本的工作目录
+}
+
+// sandboxBind 一个绑定挂载的主机路径
+// 以 root 运行时启动器映射为主机上的非特权用户，可能无法访问主机路径的上级目录，
+// 因此由父进程预先克隆出分离的挂载树，启动器只需将其挂到新根中
+type sandboxBind struct {
+	Path  string // 主机路径，也是新根中的挂载位置
+	IsDir bool   // 路径是否为目录
+	FD    int    // 启动器中分离挂载树的文件描述符，-1 表示由启动器按路径绑定
+}
+
+// Linux capability 编号
+const (
+	capSetpcap  = 8
+	capSysAdmin = 21
+)
+
+// Linux 新挂载 API 的系统调用号与标志，syscall 包未导出
+const (
+	sysOpenTree         = 428
+	sysMoveMount        = 429
+	openTreeClone       = 0x1
+	openTreeCloexec     = syscall.O_CLOEXEC
+	atRecursive         = 0x8000
+	moveMountFEmptyPath = 0x4
+	atFdcwd             = -0x64
+)
+
+// prepareCommand 将命令改为经由启动器在新的命名空间中启动
+func (s *SandboxExecutor) prepareCommand(cmd *exec.Cmd, task *Task, envDir, workDir string) error {
+	if task.WorkDir != "" {
+		return fmt.Errorf("sandboxed tasks run in their temporary work directory, WorkDir %s is not supported", task.WorkDir)
+	}
+	spec := sandboxSpec{
+		Root:    filepath.Join(workDir, ".rootfs"),
+		WorkDir: workDir,
+	}
+	if err := os.Mkdir(spec.Root, 0755); err != nil {
+		return fmt.Errorf("failed to create sandbox root: %v", err)
+	}
+
+	candidates := append(append([]string(nil), DefaultSandboxReadOnlyPaths...), s.Sandbox.ReadOnlyPaths...)
+	candidates = append(candidates, envDir)
+	candidates = append(candidates, interpreterPrefixes(envDir)...)
+	candidates = append(candidates, task.sourcePaths()...)
+	var readOnly []string
+	seen := make(map[string]bool)
+	for _, path := range candidates {
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if _, err := os.Stat(path); err == nil {
+			readOnly = append(readOnly, path)
+		}
+	}
+	// 先挂载父路径，再挂载其中的子路径
+	sort.Strings(readOnly)
+	// 可写路径（工作目录）中包含新根的挂载点，不能递归绑定
+	for _, path := range readOnly {
+		bind, err := newSandboxBind(cmd, path, true)
+		if err != nil {
+			return err
+		}
+		spec.ReadOnly = append(spec.ReadOnly, bind)
+	}
+	bind, err := newSandboxBind(cmd, workDir, false)
+	if err != nil {
+		return err
+	}
+	spec.Writable = append(spec.Writable, bind)
+
+	attr := cmd.SysProcAttr
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+		cmd.SysProcAttr = attr
+	}
+	attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !s.Sandbox.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+
+	// 命名空间内只映射一个非零的 UID 与 GID，启动器从一开始就以该用户运行，
+	// 仅凭命名空间内的 CAP_SYS_ADMIN 完成挂载，随后放弃所有能力再执行脚本
+	uid, gid := s.Sandbox.UID, s.Sandbox.GID
+	if uid == 0 {
+		uid = DefaultSandboxUID
+	}
+	if gid == 0 {
+		gid = DefaultSandboxUID
+	}
+	if os.Geteuid() == 0 {
+		// 以 root 运行时映射为主机上同号的非特权用户
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = true
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		if err := chownTree(workDir, uid, gid); err != nil {
+			return fmt.Errorf("failed to prepare sandbox work directory: %v", err)
+		}
+	} else {
+		// 非特权用户只能映射自身
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: os.Getgid(), Size: 1}}
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: true}
+	}
+	attr.AmbientCaps = []uintptr{capSetpcap, capSysAdmin}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode sandbox spec: %v", err)
+	}
+	cmd.Env = append(cmd.Env, sandboxSpecEnv+"="+string(data), "TMPDIR=/tmp")
+	return useLauncher(cmd)
+}
+
+// newSandboxBind 描述 path 的绑定挂载，以 root 运行时克隆其挂载树并作为附加文件传给启动器
+// 调用方负责在启动后关闭 cmd.ExtraFiles
+func newSandboxBind(cmd *exec.Cmd, path string, recursive bool) (sandboxBind, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return sandboxBind{}, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	bind := sandboxBind{Path: path, IsDir: info.IsDir(), FD: -1}
+	if os.Geteuid() != 0 {
+		// 非特权用户映射为自身，启动器可以按路径访问
+		return bind, nil
+	}
+
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return sandboxBind{}, err
+	}
+	flags := uintptr(openTreeClone | openTreeCloexec)
+	if recursive {
+		flags |= atRecursive
+	}
+	atCwd := atFdcwd
+	fd, _, errno := syscall.Syscall(sysOpenTree, uintptr(atCwd), uintptr(unsafe.Pointer(p)), flags)
+	if errno != 0 {
+		return sandboxBind{}, fmt.Errorf("failed to clone mount %s: %v", path, errno)
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, os.NewFile(fd, path))
+	bind.FD = 2 + len(cmd.ExtraFiles)
+	return bind, nil
+}
+
+// interpreterPrefixes 返回虚拟环境所依赖的解释器安装目录
+func interpreterPrefixes(envDir string) []string {
+	var prefixes []string
+	if f, err := os.Open(filepath.Join(envDir, "pyvenv.cfg")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), "=")
+			if ok && strings.TrimSpace(key) == "home" {
+				prefixes = append(prefixes, filepath.Dir(strings.TrimSpace(value)))
+			}
+		}
+		f.Close()
+	}
+	if real, err := filepath.EvalSymlinks(filepath.Join(envDir, "bin", "python")); err == nil {
+		prefixes = append(prefixes, filepath.Dir(filepath.Dir(real)))
+	}
+	return prefixes
+}
+
+// chownTree 递归修改目录的属主
+func chownTree(dir string, uid, gid int) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// setupSandbox 按 JSON 编码的配置构建新的根文件系统并放弃特权，由启动器在 exec 前调用
+func setupSandbox(data string) error {
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return fmt.Errorf("invalid sandbox spec: %v", err)
+	}
+
+	err := buildRootfs(&spec)
+	// 指向主机路径的描述符不能留给脚本
+	for _, bind := range append(spec.ReadOnly, spec.Writable...) {
+		if bind.FD >= 0 {
+			syscall.Close(bind.FD)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := syscall.Sethostname([]byte("sandbox")); err != nil {
+		return fmt.Errorf("failed to set hostname: %v", err)
+	}
+	if err := syscall.Chdir(spec.WorkDir); err != nil {
+		return fmt.Errorf("failed to enter work directory: %v", err)
+	}
+	return dropPrivileges()
+}
+
+// buildRootfs 在 tmpfs 上组装只包含所需路径的根文件系统并切换进去
+func buildRootfs(spec *sandboxSpec) error {
+	root := spec.Root
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %v", err)
+	}
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755,size=16m"); err != nil {
+		return fmt.Errorf("failed to mount sandbox root: %v", err)
+	}
+	if err := mountTmpfs(filepath.Join(root, "tmp"), "mode=1777"); err != nil {
+		return err
+	}
+
+	for _, bind := range spec.ReadOnly {
+		if err := bind.mount(filepath.Join(root, bind.Path), true); err != nil {
+			return err
+		}
+	}
+	for _, bind := range spec.Writable {
+		if err := bind.mount(filepath.Join(root, bind.Path), false); err != nil {
+			return err
+		}
+	}
+
+	if err := mountTmpfs(filepath.Join(root, "dev"), "mode=0755"); err != nil {
+		return err
+	}
+	for _, dev := range []string{"null", "zero", "full", "random", "urandom"} {
+		bind := sandboxBind{Path: filepath.Join("/dev", dev), FD: -1}
+		if err := bind.mount(filepath.Join(root, "dev", dev), false); err != nil {
+			return err
+		}
+	}
+
+	procDir := filepath.Join(root, "proc")
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return fmt.Errorf("failed to create /proc: %v", err)
+	}
+	if err := syscall.Mount("proc", procDir, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount /proc: %v", err)
+	}
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.Mkdir(oldRoot, 0700); err != nil {
+		return fmt.Errorf("failed to create old root: %v", err)
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("failed to pivot root: %v", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return fmt.Errorf("failed to enter new root: %v", err)
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to detach old root: %v", err)
+	}
+	os.Remove("/.oldroot")
+
+	if err := syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("failed to make sandbox root read-only: %v", err)
+	}
+	return nil
+}
+
+// mountTmpfs 在 dir 上挂载 tmpfs
+func mountTmpfs(dir, options string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	if err := syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+		return fmt.Errorf("failed to mount tmpfs on %s: %v", dir, err)
+	}
+	return nil
+}
+
+// mount 将主机路径挂载到 dst，readOnly 为 true 时重新挂载为只读
+func (b sandboxBind) mount(dst string, readOnly bool) error {
+	var err error
+	if b.IsDir {
+		err = os.MkdirAll(dst, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(dst), 0755); err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create mount point %s: %v", dst, err)
+	}
+
+	if b.FD >= 0 {
+		p, err := syscall.BytePtrFromString(dst)
+		if err != nil {
+			return err
+		}
+		var empty byte
+		atCwd := atFdcwd
+		if _, _, errno := syscall.Syscall6(sysMoveMount, uintptr(b.FD), uintptr(unsafe.Pointer(&empty)),
+			uintptr(atCwd), uintptr(unsafe.Pointer(p)), moveMountFEmptyPath, 0); errno != 0 {
+			return fmt.Errorf("failed to attach %s: %v", b.Path, errno)
+		}
+	} else {
+		flags := uintptr(syscall.MS_BIND)
+		if readOnly {
+			flags |= syscall.MS_REC
+		}
+		if err := syscall.Mount(b.Path, dst, "", flags, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %v", b.Path, err)
+		}
+	}
+	if !readOnly {
+		return nil
+	}
+
+	// 在用户命名空间中重新挂载时必须保留原挂载点上被锁定的标志
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dst, &st); err != nil {
+		return fmt.Errorf("failed to statfs %s: %v", dst, err)
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		0x2:    syscall.MS_NOSUID,
+		0x4:    syscall.MS_NODEV,
+		0x8:    syscall.MS_NOEXEC,
+		0x400:  syscall.MS_NOATIME,
+		0x800:  syscall.MS_NODIRATIME,
+		0x1000: syscall.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+	if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %v", b.Path, err)
+	}
+	return nil
+}
+
+// Linux prctl 相关常量
+const (
+	prCapbsetDrop        = 24
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+	maxCapability        = 63
+)
+
+// dropPrivileges 放弃启动器用于挂载的能力，之后 exec 也无法重新获得特权
+// 启动器已是非零 UID，清空环境能力后 exec 会丢弃其余能力；只作用于当前线程，调用方需已锁定线程并在同一线程中 exec
+func dropPrivileges() error {
+	if os.Getuid() == 0 || os.Geteuid() == 0 {
+		return fmt.Errorf("refusing to run as uid 0")
+	}
+	for c := 0; c <= maxCapability; c++ {
+		if err := prctl(prCapbsetDrop, uintptr(c)); err != nil && err != syscall.EINVAL {
+			return fmt.Errorf("failed to drop capability %d: %v", c, err)
+		}
+	}
+	if err := prctl(prCapAmbient, prCapAmbientClearAll); err != nil && err != syscall.EINVAL {
+		return fmt.Errorf("failed to clear ambient capabilities: %v", err)
+	}
+	if err := prctl(prSetNoNewPrivs, 1); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %v", err)
+	}
+	return nil
+}
+
+// prctl 调用 prctl 系统调用
+func prctl(option int, arg uintptr) error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, uintptr(option), arg, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
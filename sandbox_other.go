@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pyExecuter
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// prepareCommand 沙箱依赖 Linux 命名空间，其他平台不支持
+func (s *SandboxExecutor) prepareCommand(cmd *exec.Cmd, task *Task, envDir, workDir string) error {
+	return errors.New("sandbox is only supported on linux")
+}
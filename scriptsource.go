@@ -0,0 +1,189 @@
+package pyExecuter
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sourceEnv 向启动脚本传递脚本来源类型的环境变量
+const sourceEnv = "PYEXECUTER_SOURCE"
+
+// pythonPathEnv 向启动脚本传递额外模块搜索路径的环境变量
+const pythonPathEnv = "PYEXECUTER_PYTHONPATH"
+
+// 脚本的来源类型，与启动脚本约定
+const (
+	sourceScript     = "script"      // Task.Script 中的源码
+	sourceFile       = "file"        // Task.ScriptPath 指向的脚本文件
+	sourceArchive    = "archive"     // Task.Archive 指向的 .pyz 归档
+	sourceEntryPoint = "entry_point" // Task.EntryPoint 指定的模块或函数
+)
+
+// entryPointPattern 入口点的格式："module" 或 "module:function"，两部分都可以是以点分隔的名称
+var entryPointPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*(:[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*)?$`)
+
+// sourceKind 返回任务脚本的来源类型
+func (t *Task) sourceKind() string {
+	switch {
+	case t.ScriptPath != "":
+		return sourceFile
+	case t.Archive != "":
+		return sourceArchive
+	case t.EntryPoint != "":
+		return sourceEntryPoint
+	}
+	return sourceScript
+}
+
+// validateSource 校验任务的脚本来源：Script、ScriptPath、Archive 与 EntryPoint 最多设置一个，
+// 引用的文件与目录必须存在；设置了 PythonPath 时，入口点的模块必须能在其中找到
+func (t *Task) validateSource() error {
+	count := 0
+	for _, set := range []bool{t.Script != "", t.ScriptPath != "", t.Archive != "", t.EntryPoint != ""} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return errors.New("only one of Script, ScriptPath, Archive and EntryPoint may be set")
+	}
+
+	for _, entry := range t.PythonPath {
+		info, err := os.Stat(entry)
+		if err != nil {
+			return fmt.Errorf("invalid python path: %v", err)
+		}
+		if !info.IsDir() {
+			if _, err := zipNames(entry); err != nil {
+				return fmt.Errorf("invalid python path %s: must be a directory or zip file: %v", entry, err)
+			}
+		}
+	}
+
+	switch t.sourceKind() {
+	case sourceFile:
+		info, err := os.Stat(t.ScriptPath)
+		if err != nil {
+			return fmt.Errorf("invalid script path: %v", err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("invalid script path %s: not a regular file", t.ScriptPath)
+		}
+	case sourceArchive:
+		names, err := zipNames(t.Archive)
+		if err != nil {
+			return fmt.Errorf("invalid archive %s: %v", t.Archive, err)
+		}
+		if !names["__main__.py"] {
+			return fmt.Errorf("invalid archive %s: missing __main__.py", t.Archive)
+		}
+	case sourceEntryPoint:
+		if !entryPointPattern.MatchString(t.EntryPoint) {
+			return fmt.Errorf("invalid entry point %q: expected \"module\" or \"module:function\"", t.EntryPoint)
+		}
+		module, _, _ := strings.Cut(t.EntryPoint, ":")
+		if len(t.PythonPath) > 0 && !findModule(t.PythonPath, module) {
+			return fmt.Errorf("invalid entry point %q: module %s not found in python path", t.EntryPoint, module)
+		}
+	}
+	return nil
+}
+
+// sourceTarget 返回传给启动脚本的来源类型与目标：脚本文件、归档路径或入口点
+// scriptFile 为写入 Task.Script 的临时文件，宿主机路径会被转换为绝对路径
+func (t *Task) sourceTarget(scriptFile string) (string, string, error) {
+	kind := t.sourceKind()
+	switch kind {
+	case sourceFile:
+		target, err := filepath.Abs(t.ScriptPath)
+		return kind, target, err
+	case sourceArchive:
+		target, err := filepath.Abs(t.Archive)
+		return kind, target, err
+	case sourceEntryPoint:
+		return kind, t.EntryPoint, nil
+	}
+	return kind, scriptFile, nil
+}
+
+// pythonPath 返回转换为绝对路径的额外模块搜索路径
+func (t *Task) pythonPath() ([]string, error) {
+	paths := make([]string, 0, len(t.PythonPath))
+	for _, entry := range t.PythonPath {
+		abs, err := filepath.Abs(entry)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, abs)
+	}
+	return paths, nil
+}
+
+// sourcePaths 返回任务引用的宿主机路径，沙箱需要以只读方式暴露这些路径
+// 脚本文件只暴露其自身，同目录的其他模块需要通过 PythonPath 显式暴露
+func (t *Task) sourcePaths() []string {
+	paths, _ := t.pythonPath()
+	for _, p := range []string{t.ScriptPath, t.Archive} {
+		if p == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(p); err == nil {
+			paths = append(paths, abs)
+		}
+	}
+	return paths
+}
+
+// zipNames 返回 zip 文件中的所有文件名，允许归档前带有 shebang 等数据
+func zipNames(file string) (map[string]bool, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	names := make(map[string]bool, len(r.File))
+	for _, f := range r.File {
+		names[f.Name] = true
+	}
+	return names, nil
+}
+
+// findModule 判断模块能否在 paths 中的目录或 zip 文件里找到
+func findModule(paths []string, module string) bool {
+	rel := strings.ReplaceAll(module, ".", "/")
+	for _, entry := range paths {
+		info, err := os.Stat(entry)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			base := filepath.Join(entry, filepath.FromSlash(rel))
+			if _, err := os.Stat(base + ".py"); err == nil {
+				return true
+			}
+			// 普通包或命名空间包
+			if info, err := os.Stat(base); err == nil && info.IsDir() {
+				return true
+			}
+			if matches, _ := filepath.Glob(base + ".*.so"); len(matches) > 0 {
+				return true
+			}
+			continue
+		}
+		names, err := zipNames(entry)
+		if err != nil {
+			continue
+		}
+		for name := range names {
+			if name == rel+".py" || name == rel+".pyc" || strings.HasPrefix(name, rel+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
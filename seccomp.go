@@ -0,0 +1,187 @@
+package pyExecuter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// seccompErrorPrefix 启动器安装 seccomp 过滤器失败时写入标准错误的前缀
+const seccompErrorPrefix = "pyexecuter seccomp: "
+
+// seccompFilterEnv 向启动器传递编译后的 seccomp 过滤器的环境变量
+const seccompFilterEnv = "PYEXECUTER_SECCOMP"
+
+// seccompTrapEnv 规则中使用 trap 时通知启动脚本安装 SIGSYS 处理器以报告被拒绝的系统调用
+const seccompTrapEnv = "PYEXECUTER_SECCOMP_TRAP"
+
+// seccompViolationMarker 启动脚本在被 seccomp 拒绝时写入标准错误的标记，其后为系统调用号
+const seccompViolationMarker = "pyexecuter: seccomp violation: syscall="
+
+// SeccompAction 系统调用匹配规则时采取的动作
+type SeccompAction string
+
+const (
+	SeccompAllow SeccompAction = "allow" // 允许调用
+	SeccompErrno SeccompAction = "errno" // 拒绝调用并返回 EPERM
+	SeccompKill  SeccompAction = "kill"  // 由内核立即以 SIGSYS 终止整个进程，不经过任何信号处理器
+	// SeccompTrap 向进程发送 SIGSYS，由启动脚本的处理器报告系统调用后终止进程
+	// 处理器运行在被限制的解释器中，脚本可以替换处理器或屏蔽 SIGSYS 继续运行，只应用于调试规则
+	SeccompTrap SeccompAction = "trap"
+)
+
+// SeccompArg 对系统调用参数的匹配条件：(参数 & Mask) == Value
+type SeccompArg struct {
+	Index uint   `json:"index"` // 参数序号（0-5）
+	Value uint64 `json:"value"` // 期望值
+	Mask  uint64 `json:"mask"`  // 比较前与参数按位与的掩码，0 表示比较全部位
+}
+
+// SeccompRule 一条 seccomp 规则，Args 中的条件需全部满足
+// 多条规则匹配同一系统调用时，以先出现的规则为准
+type SeccompRule struct {
+	Names  []string      `json:"names"`           // 系统调用名称，当前架构不存在的名称会被忽略
+	Action SeccompAction `json:"action"`          // 匹配时采取的动作
+	Args   []SeccompArg  `json:"args,omitempty"`  // 参数匹配条件
+	Errno  uint32        `json:"errno,omitempty"` // 动作为 errno 时返回的错误码，0 表示 EPERM
+}
+
+// cloneNamespaceFlags clone 创建新命名空间的标志：CLONE_NEWNS、CLONE_NEWCGROUP、CLONE_NEWUTS、
+// CLONE_NEWIPC、CLONE_NEWUSER、CLONE_NEWPID 与 CLONE_NEWNET
+var cloneNamespaceFlags = []uint64{0x00020000, 0x02000000, 0x04000000, 0x08000000, 0x10000000, 0x20000000, 0x40000000}
+
+// errnoENOSYS Linux 的 ENOSYS 错误码
+const errnoENOSYS = 38
+
+// SeccompProfile 在执行Python前应用到进程的 seccomp 过滤规则
+// DefaultAction 为 allow 时为黑名单模式；否则为白名单模式，此时必须允许 execve，否则无法启动Python
+type SeccompProfile struct {
+	DefaultAction SeccompAction `json:"defaultAction"` // 不匹配任何规则时的动作，为空时视为 allow
+	Syscalls      []SeccompRule `json:"syscalls"`      // 规则列表
+}
+
+// DefaultSeccompProfile 返回默认的黑名单规则
+// 它拒绝调试其他进程、挂载文件系统、加载内核模块或新内核、创建命名空间以及使用原始套接字等操作
+// clone3 的参数位于用户内存中，无法按标志过滤，因此总是返回 ENOSYS，glibc 随后退回到可以过滤的 clone
+func DefaultSeccompProfile() *SeccompProfile {
+	profile := &SeccompProfile{
+		DefaultAction: SeccompAllow,
+		Syscalls: []SeccompRule{
+			{
+				Names: []string{
+					"ptrace", "process_vm_readv", "process_vm_writev",
+					"mount", "umount2", "pivot_root", "open_tree", "move_mount", "fsopen", "fsconfig", "fsmount", "fspick", "mount_setattr",
+					"kexec_load", "kexec_file_load", "reboot",
+					"init_module", "finit_module", "delete_module",
+					"swapon", "swapoff", "acct", "quotactl", "quotactl_fd",
+					"settimeofday", "clock_settime", "clock_adjtime", "adjtimex",
+					"unshare", "setns", "open_by_handle_at",
+					"bpf", "perf_event_open", "userfaultfd",
+					"keyctl", "add_key", "request_key",
+				},
+				Action: SeccompKill,
+			},
+			// socket(domain, type, protocol)：拒绝 SOCK_RAW 与 AF_PACKET
+			{Names: []string{"socket"}, Action: SeccompKill, Args: []SeccompArg{{Index: 1, Value: 3, Mask: 0xf}}},
+			{Names: []string{"socket"}, Action: SeccompKill, Args: []SeccompArg{{Index: 0, Value: 17, Mask: 0xffffffff}}},
+			{Names: []string{"clone3"}, Action: SeccompErrno, Errno: errnoENOSYS},
+		},
+	}
+	// clone(flags, ...)：带有任一命名空间标志时与 unshare 一样终止进程
+	for _, flag := range cloneNamespaceFlags {
+		profile.Syscalls = append(profile.Syscalls, SeccompRule{
+			Names:  []string{"clone"},
+			Action: SeccompKill,
+			Args:   []SeccompArg{{Index: 0, Value: flag, Mask: flag}},
+		})
+	}
+	return profile
+}
+
+// ParseSeccompProfile 解析 JSON 格式的 seccomp 规则
+func ParseSeccompProfile(data []byte) (*SeccompProfile, error) {
+	var profile SeccompProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("invalid seccomp profile: %v", err)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// LoadSeccompProfile 从 JSON 文件加载 seccomp 规则
+func LoadSeccompProfile(path string) (*SeccompProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seccomp profile: %v", err)
+	}
+	return ParseSeccompProfile(data)
+}
+
+// defaultAction 返回生效的默认动作
+func (p *SeccompProfile) defaultAction() SeccompAction {
+	if p.DefaultAction == "" {
+		return SeccompAllow
+	}
+	return p.DefaultAction
+}
+
+// validate 检查规则是否合法
+func (p *SeccompProfile) validate() error {
+	if !validSeccompAction(p.defaultAction()) {
+		return fmt.Errorf("invalid seccomp profile: unknown default action %q", p.DefaultAction)
+	}
+	// 第一条无参数条件、包含 execve 的规则决定启动器能否执行Python，没有这样的规则时由默认动作决定
+	execAction := SeccompAction("")
+	for i, rule := range p.Syscalls {
+		if len(rule.Names) == 0 {
+			return fmt.Errorf("invalid seccomp profile: rule %d has no syscall names", i)
+		}
+		if !validSeccompAction(rule.Action) {
+			return fmt.Errorf("invalid seccomp profile: rule %d has unknown action %q", i, rule.Action)
+		}
+		if rule.Errno != 0 && (rule.Action != SeccompErrno || rule.Errno > 4095) {
+			return fmt.Errorf("invalid seccomp profile: rule %d has invalid errno %d for action %q", i, rule.Errno, rule.Action)
+		}
+		for _, arg := range rule.Args {
+			if arg.Index > 5 {
+				return fmt.Errorf("invalid seccomp profile: rule %d has argument index %d out of range", i, arg.Index)
+			}
+		}
+		for _, name := range rule.Names {
+			if name == "execve" && len(rule.Args) == 0 && execAction == "" {
+				execAction = rule.Action
+			}
+		}
+	}
+	if execAction == "" {
+		execAction = p.defaultAction()
+	}
+	if execAction != SeccompAllow {
+		return fmt.Errorf("invalid seccomp profile: execve must be allowed to start python")
+	}
+	return nil
+}
+
+// traps 判断规则是否使用 trap 动作
+func (p *SeccompProfile) traps() bool {
+	if p.defaultAction() == SeccompTrap {
+		return true
+	}
+	for _, rule := range p.Syscalls {
+		if rule.Action == SeccompTrap {
+			return true
+		}
+	}
+	return false
+}
+
+// validSeccompAction 判断动作是否合法
+func validSeccompAction(action SeccompAction) bool {
+	switch action {
+	case SeccompAllow, SeccompErrno, SeccompKill, SeccompTrap:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package pyExecuter
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// seccomp 相关的内核常量
+const (
+	prSetSeccomp       = 22
+	seccompModeFilter  = 2
+	bpfMaxInstructions = 4096
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	// seccomp_data 中各字段的偏移
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+)
+
+// applySeccomp 编译 seccomp 规则，并让命令经由启动器在执行Python前安装过滤器
+func applySeccomp(cmd *exec.Cmd, profile *SeccompProfile) error {
+	filter, err := compileSeccomp(profile)
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(cmd.Env, seccompFilterEnv+"="+encodeSeccompFilter(filter))
+	if profile.traps() {
+		cmd.Env = append(cmd.Env, seccompTrapEnv+"=1")
+	}
+	return useLauncher(cmd)
+}
+
+// compileSeccomp 将规则编译为 BPF 程序
+// 架构不符的调用与规则中的 kill 直接终止进程；trap 使用 SECCOMP_RET_TRAP，以便启动脚本报告被拒绝的系统调用
+func compileSeccomp(profile *SeccompProfile) ([]syscall.SockFilter, error) {
+	if seccompArch == 0 {
+		return nil, fmt.Errorf("seccomp is not supported on %s", runtime.GOARCH)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+
+	prog := []syscall.SockFilter{
+		bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArch),
+		bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, seccompArch, 1, 0),
+		bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+	}
+	if seccompMaxSyscall > 0 {
+		prog = append(prog,
+			bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNr),
+			bpfJump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, seccompMaxSyscall, 0, 1),
+			bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+		)
+	}
+
+	for _, rule := range profile.Syscalls {
+		checks := seccompArgChecks(rule.Args)
+		for _, name := range rule.Names {
+			nr, ok := seccompSyscalls[name]
+			if !ok {
+				continue
+			}
+			prog = append(prog,
+				bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNr),
+				bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, uint8(len(checks)+1)),
+			)
+			prog = append(prog, checks...)
+			prog = append(prog, bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRet(rule.Action, rule.Errno)))
+		}
+	}
+	prog = append(prog, bpfStmt(syscall.BPF_RET|syscall.BPF_K, seccompRet(profile.defaultAction(), 0)))
+
+	if len(prog) > bpfMaxInstructions {
+		return nil, fmt.Errorf("seccomp profile too large: %d instructions", len(prog))
+	}
+	return prog, nil
+}
+
+// seccompArgChecks 生成参数匹配条件，任一条件不满足时跳过其后的返回指令
+// 参数为 64 位，分别比较低 32 位与高 32 位（仅支持小端架构）
+func seccompArgChecks(args []SeccompArg) []syscall.SockFilter {
+	var checks []syscall.SockFilter
+	for _, arg := range args {
+		mask := arg.Mask
+		if mask == 0 {
+			mask = ^uint64(0)
+		}
+		offset := seccompDataArgs + 8*uint32(arg.Index)
+		for half := uint32(0); half < 2; half++ {
+			m, v := uint32(mask>>(32*half)), uint32(arg.Value>>(32*half))
+			if m == 0 && v == 0 {
+				continue
+			}
+			checks = append(checks,
+				bpfStmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offset+4*half),
+				bpfStmt(syscall.BPF_ALU|syscall.BPF_AND|syscall.BPF_K, m),
+				bpfJump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, v, 0, 0),
+			)
+		}
+	}
+	for i := range checks {
+		if checks[i].Code == syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K {
+			// 跳过剩余的条件以及返回指令
+			checks[i].Jf = uint8(len(checks) - i)
+		}
+	}
+	return checks
+}
+
+// seccompRet 返回动作对应的过滤器返回值，errno 为 0 时 errno 动作返回 EPERM
+func seccompRet(action SeccompAction, errno uint32) uint32 {
+	switch action {
+	case SeccompErrno:
+		if errno == 0 {
+			errno = uint32(syscall.EPERM)
+		}
+		return seccompRetErrno | errno
+	case SeccompKill:
+		return seccompRetKillProcess
+	case SeccompTrap:
+		return seccompRetTrap
+	}
+	return seccompRetAllow
+}
+
+func bpfStmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// encodeSeccompFilter 将 BPF 程序编码为可通过环境变量传递的字符串
+func encodeSeccompFilter(filter []syscall.SockFilter) string {
+	data := make([]byte, 8*len(filter))
+	for i, f := range filter {
+		b := data[8*i:]
+		binary.LittleEndian.PutUint16(b, f.Code)
+		b[2], b[3] = f.Jt, f.Jf
+		binary.LittleEndian.PutUint32(b[4:], f.K)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// installSeccomp 解码并为当前线程安装 seccomp 过滤器，由启动器在 exec 前调用
+func installSeccomp(encoded string) error {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) == 0 || len(data)%8 != 0 {
+		return fmt.Errorf("invalid seccomp filter")
+	}
+	filter := make([]syscall.SockFilter, len(data)/8)
+	for i := range filter {
+		b := data[8*i:]
+		filter[i] = bpfJump(binary.LittleEndian.Uint16(b), binary.LittleEndian.Uint32(b[4:]), b[2], b[3])
+	}
+
+	// 非特权进程安装过滤器前必须设置 no_new_privs
+	if err := prctl(prSetNoNewPrivs, 1); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %v", err)
+	}
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to install seccomp filter: %v", errno)
+	}
+	return nil
+}
+
+// detectSeccompViolation 判断进程是否因违反 seccomp 规则被终止
+// 被 kill 终止时无法得知具体的系统调用；trap 时启动脚本写入的违规标记会从标准错误中移除，并据此记录被拒绝的系统调用
+// 沙箱中的Python是 PID 命名空间的 init 进程，无法被 SIGSYS 终止，此时以退出码 128+SIGSYS 退出
+func detectSeccompViolation(res *ExecutionResult) {
+	i := strings.LastIndex(res.Stderr, seccompViolationMarker)
+	if res.Signal != syscall.SIGSYS && (i < 0 || res.ExitCode != 128+int(syscall.SIGSYS)) {
+		return
+	}
+	res.SeccompViolation = true
+	if i < 0 {
+		return
+	}
+	line, rest, _ := strings.Cut(res.Stderr[i+len(seccompViolationMarker):], "\n")
+	nr, err := strconv.ParseUint(strings.TrimSpace(line), 10, 32)
+	if err != nil {
+		return
+	}
+	res.Stderr = res.Stderr[:i] + rest
+	res.SeccompSyscall = seccompSyscallName(uint32(nr))
+}
+
+var (
+	seccompNames     map[uint32]string
+	seccompNamesOnce sync.Once
+)
+
+// seccompSyscallName 返回系统调用号对应的名称，未知时返回调用号本身
+func seccompSyscallName(nr uint32) string {
+	seccompNamesOnce.Do(func() {
+		seccompNames = make(map[uint32]string, len(seccompSyscalls))
+		for name, n := range seccompSyscalls {
+			// 同一调用号有多个名称时取字典序最小者，保证结果稳定
+			if old, ok := seccompNames[n]; !ok || name < old {
+				seccompNames[n] = name
+			}
+		}
+	})
+	if name, ok := seccompNames[nr]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(nr), 10)
+}
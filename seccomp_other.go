@@ -0,0 +1,16 @@
+//go:build !linux
+
+package pyExecuter
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// applySeccomp seccomp 依赖 Linux 内核，其他平台不支持
+func applySeccomp(cmd *exec.Cmd, profile *SeccompProfile) error {
+	return errors.New("seccomp is only supported on linux")
+}
+
+// detectSeccompViolation 其他平台不会发生 seccomp 违规
+func detectSeccompViolation(res *ExecutionResult) {}
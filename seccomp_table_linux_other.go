@@ -0,0 +1,12 @@
+//go:build linux && !amd64 && !arm64
+
+package pyExecuter
+
+// seccompArch 当前架构没有内置的系统调用表，为 0 表示不支持 seccomp
+const seccompArch = 0
+
+// seccompMaxSyscall 调用号上限，为 0 表示不检查
+const seccompMaxSyscall = 0
+
+// seccompSyscalls 当前架构没有内置的系统调用表
+var seccompSyscalls = map[string]uint32{}
@@ -0,0 +1,435 @@
+package pyExecuter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSessionTTL 会话默认的空闲过期时间
+const DefaultSessionTTL = 30 * time.Minute
+
+// ErrSessionClosed 会话已关闭或已过期
+var ErrSessionClosed = errors.New("session is closed")
+
+// SessionOptions 创建会话的选项，字段含义与 Task 的同名字段相同
+type SessionOptions struct {
+	Env          map[string]string   // 会话的环境变量（可选）
+	SecretEnv    map[string]string   // 敏感的环境变量（可选）
+	EnvInherit   EnvInheritance      // 从当前进程继承环境变量的策略
+	EnvAllowlist []string            // InheritAllowlist 策略下允许继承的变量
+	WorkDir      string              // 解释器的当前目录，为空时使用当前进程的目录
+	Dependencies *DependencySpec     // 会话所需的Python依赖（可选）
+	Interpreter  InterpreterSelector // 会话使用的Python解释器（可选）
+	OutputLimits *OutputLimits       // 每个代码单元的输出上限，为 nil 时使用执行器的默认值，不支持 OutputKill
+}
+
+// task 返回与选项对应的任务，用于复用任务的校验与环境变量构造
+func (o SessionOptions) task() *Task {
+	return &Task{
+		Env:          o.Env,
+		SecretEnv:    o.SecretEnv,
+		EnvInherit:   o.EnvInherit,
+		EnvAllowlist: o.EnvAllowlist,
+		WorkDir:      o.WorkDir,
+		Dependencies: o.Dependencies,
+		Interpreter:  o.Interpreter,
+		OutputLimits: o.OutputLimits,
+	}
+}
+
+// CellResult 一个代码单元的执行结果
+type CellResult struct {
+	ExecutionCount  int             // 代码单元在会话中的序号，从1开始
+	Stdout          string          // 标准输出（按输出上限截断）
+	Stderr          string          // 标准错误（按输出上限截断）
+	StdoutTruncated bool            // 标准输出是否超过上限
+	StderrTruncated bool            // 标准错误是否超过上限
+	ExitCode        int             // 代码单元调用 sys.exit 时的退出码，会话不会因此结束
+	Repr            string          // 最后一条语句为表达式时其值的 repr()，值为 None 时为空
+	Value           interface{}     // 最后一个表达式的值按 JSON 解码的结果，无法编码为 JSON 时为 nil
+	RawValue        json.RawMessage // Value 的原始 JSON
+	Result          interface{}     // 代码单元通过 pyexecuter.set_result 返回的值
+	RawResult       json.RawMessage // Result 的原始 JSON
+	Exception       *PythonError    // 未捕获的异常，没有异常时为 nil
+	Interrupted     bool            // 代码单元是否被中断
+	CPUTime         time.Duration
+	WallTime        time.Duration
+}
+
+// Session 一个长期存在的Python解释器，代码单元依次在共享的全局命名空间中执行
+// 同一会话同时只执行一个代码单元，并发的 Run 调用按顺序等待
+// 会话不支持沙箱与资源限制，seccomp 规则沿用创建它的执行器的配置
+type Session struct {
+	ID            string // 会话的唯一ID
+	PythonVersion string // 会话使用的Python版本
+
+	worker   *worker
+	release  func()
+	limits   OutputLimits
+	grace    time.Duration
+	onClose  func()
+	run      sync.Mutex // 保证代码单元依次执行
+	mu       sync.Mutex // 保护以下字段
+	running  bool       // 已发送代码单元，尚未收到结果
+	started  bool       // worker 已确认代码单元开始执行，此后才能发送 SIGINT
+	pending  bool       // 代码单元开始执行前收到的中断，开始执行时立即发送
+	closed   bool
+	lastUsed time.Time
+}
+
+// Run 执行一个代码单元，最后一条语句是表达式时返回其值
+// ctx 结束时先中断代码单元，会话继续可用；代码单元在宽限期内没有停止时会话被关闭
+func (s *Session) Run(ctx context.Context, code string) (*CellResult, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("cell execution cancelled: %w", err)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrSessionClosed
+	}
+	s.running = true
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+
+	req := workerRequest{Script: code}
+	if s.limits.StdoutBytes > 0 || s.limits.StderrBytes > 0 {
+		req.Output = &workerOutput{Stdout: s.limits.StdoutBytes, Stderr: s.limits.StderrBytes, Policy: s.limits.Policy.String()}
+	}
+
+	type reply struct {
+		resp *workerResponse
+		err  error
+	}
+	done := make(chan reply, 1)
+	start := time.Now()
+	go func() {
+		resp := &workerResponse{}
+		err := s.worker.send(req)
+		for err == nil {
+			if err = s.worker.receive(resp); err != nil || !resp.Started {
+				break
+			}
+			s.cellStarted()
+			resp = &workerResponse{}
+		}
+		done <- reply{resp, err}
+	}()
+
+	var r reply
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		s.Interrupt()
+		grace := s.grace
+		if grace <= 0 {
+			grace = DefaultKillGracePeriod
+		}
+		select {
+		case r = <-done:
+		case <-time.After(grace):
+			s.Close()
+			<-done
+			return nil, fmt.Errorf("cell did not stop after interrupt, session closed: %w", ctx.Err())
+		}
+	}
+
+	s.mu.Lock()
+	s.running, s.started, s.pending = false, false, false
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+
+	if r.err != nil {
+		// 读取失败意味着 worker 已退出，会话无法继续使用
+		stderr := s.worker.stderr.String()
+		s.Close()
+		if stderr != "" {
+			return nil, fmt.Errorf("session worker exited: %v: %s", r.err, stderr)
+		}
+		return nil, fmt.Errorf("session worker exited: %v", r.err)
+	}
+
+	resp := r.resp
+	res := &CellResult{
+		ExecutionCount:  resp.ExecutionCount,
+		Stdout:          resp.Stdout,
+		Stderr:          resp.Stderr,
+		StdoutTruncated: s.limits.StdoutBytes > 0 && resp.StdoutBytes > s.limits.StdoutBytes,
+		StderrTruncated: s.limits.StderrBytes > 0 && resp.StderrBytes > s.limits.StderrBytes,
+		ExitCode:        resp.ExitCode,
+		CPUTime:         time.Duration(resp.CPU * float64(time.Second)),
+		WallTime:        time.Since(start),
+	}
+	if resp.Repr != nil {
+		res.Repr = *resp.Repr
+	}
+	var decodeErr error
+	if resp.Value != nil {
+		res.RawValue = json.RawMessage(*resp.Value)
+		if err := json.Unmarshal(res.RawValue, &res.Value); err != nil {
+			decodeErr = fmt.Errorf("failed to decode cell value: %v", err)
+		}
+	}
+	if resp.Result != nil {
+		var decoded ExecutionResult
+		if err := decodeResult(&decoded, []byte(*resp.Result)); err != nil && decodeErr == nil {
+			decodeErr = err
+		}
+		res.Result, res.RawResult = decoded.Value, decoded.RawValue
+	}
+	if len(resp.Exception) > 0 {
+		res.Exception = parsePythonError(resp.Exception, "", "")
+		res.Interrupted = res.Exception != nil && res.Exception.Type == "KeyboardInterrupt"
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return res, fmt.Errorf("cell execution timed out: %w", ctx.Err())
+	case ctx.Err() != nil:
+		return res, fmt.Errorf("cell execution cancelled: %w", ctx.Err())
+	case res.Exception != nil:
+		return res, fmt.Errorf("cell execution failed: %w", res.Exception)
+	case res.ExitCode != 0:
+		return res, fmt.Errorf("cell execution failed: exit status %d", res.ExitCode)
+	case decodeErr != nil:
+		return res, decodeErr
+	}
+	return res, nil
+}
+
+// Interrupt 中断正在执行的代码单元，代码单元以 KeyboardInterrupt 结束，会话继续可用
+// 没有正在执行的代码单元时不做任何操作
+func (s *Session) Interrupt() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSessionClosed
+	}
+	if !s.running {
+		return nil
+	}
+	if !s.started {
+		// worker 尚未开始执行代码单元，此时的 SIGINT 会被当作空闲时的中断忽略
+		s.pending = true
+		return nil
+	}
+	return s.worker.cmd.Process.Signal(os.Interrupt)
+}
+
+// cellStarted 记录 worker 已开始执行代码单元，并发送此前推迟的中断
+func (s *Session) cellStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	if s.pending && !s.closed {
+		s.pending = false
+		s.worker.cmd.Process.Signal(os.Interrupt)
+	}
+}
+
+// Close 终止会话的解释器并归还其占用的虚拟环境，重复调用是安全的
+func (s *Session) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.worker.kill(s.grace)
+	s.release()
+	if s.onClose != nil {
+		s.onClose()
+	}
+	return nil
+}
+
+// Closed 返回会话是否已关闭
+func (s *Session) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// LastUsed 返回会话最后一次执行代码单元的时间
+func (s *Session) LastUsed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed
+}
+
+// idleSince 返回会话是否在 deadline 之前就已空闲，正在执行代码单元的会话不算空闲
+func (s *Session) idleSince(deadline time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.closed && !s.running && s.lastUsed.Before(deadline)
+}
+
+// SessionManager 创建并管理会话，空闲超过 TTL 的会话会被自动关闭
+type SessionManager struct {
+	Executor *SecurePythonExecutor // 提供环境管理、seccomp 与默认输出限制的执行器
+	TTL      time.Duration         // 会话的空闲过期时间
+
+	sessions map[string]*Session
+	closed   bool
+	stop     chan struct{}
+	mu       sync.Mutex
+}
+
+// NewSessionManager 创建 SessionManager 实例，ttl 为0时使用 DefaultSessionTTL
+func NewSessionManager(executor *SecurePythonExecutor, ttl time.Duration) *SessionManager {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	m := &SessionManager{
+		Executor: executor,
+		TTL:      ttl,
+		sessions: make(map[string]*Session),
+		stop:     make(chan struct{}),
+	}
+	go m.reap()
+	return m
+}
+
+// Create 启动一个新的会话
+func (m *SessionManager) Create(ctx context.Context, opts SessionOptions) (*Session, error) {
+	task := opts.task()
+	if err := task.validateEnv(); err != nil {
+		return nil, err
+	}
+	if err := task.OutputLimits.validate(); err != nil {
+		return nil, err
+	}
+	limits := task.outputLimits(m.Executor.OutputLimits)
+	if limits.Policy == OutputKill {
+		return nil, errors.New("output policy kill is not supported for sessions")
+	}
+
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return nil, ErrSessionClosed
+	}
+
+	envDir, version, release, err := m.Executor.acquireEnvironment(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	w, err := startWorker(envDir, m.Executor.Seccomp, task)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// 登记会话的敏感环境变量，会话关闭后保留到调用 ReleaseTaskSecrets
+	unregister := secrets.register(task)
+	s := &Session{
+		ID:            randomID(),
+		PythonVersion: version,
+		worker:        w,
+		release:       func() { release(); unregister() },
+		limits:        limits,
+		grace:         m.Executor.KillGracePeriod,
+		lastUsed:      time.Now(),
+	}
+	s.onClose = func() { m.remove(s.ID) }
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		s.Close()
+		return nil, ErrSessionClosed
+	}
+	m.sessions[s.ID] = s
+	m.mu.Unlock()
+	return s, nil
+}
+
+// Get 返回指定ID的会话，会话不存在、已关闭或已过期时返回 false
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// Sessions 返回所有活动会话的ID
+func (m *SessionManager) Sessions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Close 关闭所有会话并停止过期检查，关闭后不能再创建会话
+func (m *SessionManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stop)
+	sessions := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.Close()
+	}
+	return nil
+}
+
+// remove 从管理器中移除已关闭的会话
+func (m *SessionManager) remove(id string) {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+}
+
+// reap 定期关闭空闲超过 TTL 的会话
+func (m *SessionManager) reap() {
+	interval := m.TTL / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			deadline := now.Add(-m.TTL)
+			var expired []*Session
+			m.mu.Lock()
+			for _, s := range m.sessions {
+				if s.idleSince(deadline) {
+					expired = append(expired, s)
+				}
+			}
+			m.mu.Unlock()
+			for _, s := range expired {
+				s.Close()
+			}
+		}
+	}
+}
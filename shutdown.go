@@ -0,0 +1,151 @@
+package pyExecuter
+
+import (
+	"context"
+	"time"
+)
+
+// ShutdownReport 执行器关闭后的汇总
+type ShutdownReport struct {
+	Succeeded   int           // 执行器运行期间成功的任务数
+	Failed      int           // 执行器运行期间最终失败的任务数
+	Cancelled   int           // 执行器运行期间被取消的任务数，包括关闭时被终止或仍在队列中的任务
+	Interrupted []string      // 关闭时被终止的正在执行的任务ID
+	Abandoned   []string      // 关闭时仍在队列中、没有执行的任务ID
+	Persisted   bool          // 被终止与未执行的任务是否已保存到 Recovery（须实现 PendingTaskStore）
+	Duration    time.Duration // 关闭所用的时间
+}
+
+// Shutdown 关闭执行器：立即停止接受新任务，Submit 与 AddTask 返回 ErrExecutorClosed；
+// DrainOnShutdown 为 true 时继续执行队列中的任务（执行器处于暂停状态时不会执行），
+// 否则停止分发，然后等待正在执行的任务结束。
+// ctx 结束时终止剩余的任务，被终止的任务与仍在队列中的任务以 TaskCancelled 状态结束，
+// Recovery 实现了 PendingTaskStore 时保存到其中，重启后可以通过 PendingTasks 取回并重新提交。
+// 重复调用时等待第一次关闭完成并返回相同的汇总
+func (e *GopoolExecutor) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	first := false
+	e.shutdownOnce.Do(func() { first = true })
+	if !first {
+		select {
+		case <-e.stopped:
+			return e.report, e.shutdownErr
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	start := time.Now()
+	e.mu.Lock()
+	e.closing = true
+	drain := e.DrainOnShutdown && e.dispatched != nil
+	size := e.size
+	e.mu.Unlock()
+	e.emit(ExecutorEvent{Type: EventShuttingDown, PoolSize: size})
+
+	if !drain {
+		e.stop()
+	}
+	e.waitIdle(ctx, drain)
+	e.stop()
+
+	// 终止剩余的任务并等待其进程组退出
+	e.mu.Lock()
+	for _, cancel := range e.running {
+		cancel(ErrExecutorClosed)
+	}
+	e.mu.Unlock()
+	e.waitIdle(context.Background(), false)
+	e.mu.Lock()
+	pool := e.pool
+	e.mu.Unlock()
+	pool.Release()
+
+	var abandoned []*Task
+	for {
+		task, err := e.Queue.GetTask()
+		if err != nil {
+			break
+		}
+		abandoned = append(abandoned, task)
+		e.cancelQueued(task, ErrExecutorClosed)
+	}
+
+	e.mu.Lock()
+	interrupted := e.interrupted
+	report := &ShutdownReport{
+		Succeeded: e.finished[TaskSucceeded],
+		Failed:    e.finished[TaskFailed],
+		Cancelled: e.finished[TaskCancelled],
+	}
+	e.mu.Unlock()
+	for _, task := range interrupted {
+		report.Interrupted = append(report.Interrupted, task.ID)
+	}
+	for _, task := range abandoned {
+		report.Abandoned = append(report.Abandoned, task.ID)
+	}
+
+	var err error
+	if store, ok := e.Recovery.(PendingTaskStore); ok {
+		// 没有未完成的任务时同样保存，清除上一次保存的任务
+		pending := append(append([]*Task(nil), interrupted...), abandoned...)
+		err = store.SaveTasks(pending)
+		report.Persisted = err == nil
+	}
+	report.Duration = time.Since(start)
+
+	e.report, e.shutdownErr = report, err
+	close(e.stopped)
+	e.emit(ExecutorEvent{Type: EventStopped, PoolSize: size})
+	return report, err
+}
+
+// Stopped 返回执行器关闭完成后关闭的 channel
+func (e *GopoolExecutor) Stopped() <-chan struct{} {
+	return e.stopped
+}
+
+// stop 停止分发任务并等待分发协程退出（内部方法）
+func (e *GopoolExecutor) stop() {
+	e.mu.Lock()
+	stop, dispatched := e.stopDispatch, e.dispatched
+	e.mu.Unlock()
+	if stop != nil {
+		stop()
+		<-dispatched
+	}
+}
+
+// waitIdle 等待没有正在执行的任务，drain 为 true 时还要等待队列为空，ctx 结束时提前返回（内部方法）
+func (e *GopoolExecutor) waitIdle(ctx context.Context, drain bool) {
+	for {
+		e.mu.Lock()
+		idle := len(e.running) == 0 && (!drain || e.Queue.Size() == 0)
+		changed := e.changedLocked()
+		e.mu.Unlock()
+		if idle {
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// changedLocked 返回下一次状态变化时关闭的 channel，调用方须持有 e.mu（内部方法）
+func (e *GopoolExecutor) changedLocked() <-chan struct{} {
+	if e.changed == nil {
+		e.changed = make(chan struct{})
+	}
+	return e.changed
+}
+
+// notifyLocked 唤醒等待任务结束、执行名额或恢复分发的协程，调用方须持有 e.mu（内部方法）
+func (e *GopoolExecutor) notifyLocked() {
+	if e.changed != nil {
+		close(e.changed)
+		e.changed = nil
+	}
+}
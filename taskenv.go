@@ -0,0 +1,297 @@
+package pyExecuter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue 日志中替换敏感值的文本
+const RedactedValue = "[REDACTED]"
+
+// MinSecretLength SecretEnv 中值的最小长度，过短的值（如 "1"、"true"）脱敏时会误伤无关的日志文本
+const MinSecretLength = 8
+
+// EnvInheritance 任务从当前进程继承环境变量的策略
+type EnvInheritance int
+
+const (
+	InheritAllowlist EnvInheritance = iota // 只继承允许列表中的变量（默认）
+	InheritNone                            // 不继承任何变量
+	InheritAll                             // 继承当前进程的全部变量
+)
+
+// DefaultEnvAllowlist 任务未指定 EnvAllowlist 时允许继承的变量，以 "*" 结尾的条目按前缀匹配
+var DefaultEnvAllowlist = []string{"HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ", "TMPDIR", "LANG", "LANGUAGE", "LC_*"}
+
+// String 返回策略的名称
+func (i EnvInheritance) String() string {
+	switch i {
+	case InheritAllowlist:
+		return "allowlist"
+	case InheritNone:
+		return "none"
+	case InheritAll:
+		return "all"
+	}
+	return fmt.Sprintf("EnvInheritance(%d)", int(i))
+}
+
+// validateEnv 校验任务的环境变量、继承策略与工作目录
+func (t *Task) validateEnv() error {
+	if t.EnvInherit < InheritAllowlist || t.EnvInherit > InheritAll {
+		return fmt.Errorf("invalid env inheritance policy %v", t.EnvInherit)
+	}
+	for _, env := range []map[string]string{t.Env, t.SecretEnv} {
+		for name, value := range env {
+			if name == "" || strings.ContainsAny(name, "=\x00") {
+				return fmt.Errorf("invalid environment variable name %q", name)
+			}
+			if strings.ContainsRune(value, 0) {
+				return fmt.Errorf("invalid value for environment variable %s: contains NUL", name)
+			}
+		}
+	}
+	for name, value := range t.SecretEnv {
+		if _, ok := t.Env[name]; ok {
+			return fmt.Errorf("environment variable %s is set in both Env and SecretEnv", name)
+		}
+		if len(value) < MinSecretLength {
+			return fmt.Errorf("secret environment variable %s is shorter than %d characters", name, MinSecretLength)
+		}
+	}
+	if t.WorkDir != "" {
+		info, err := os.Stat(t.WorkDir)
+		if err != nil {
+			return fmt.Errorf("invalid work directory: %v", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid work directory %s: not a directory", t.WorkDir)
+		}
+	}
+	return nil
+}
+
+// environ 返回脚本进程的环境变量：按继承策略保留的当前进程变量、PYTHONUNBUFFERED、任务的变量，
+// 最后是虚拟环境的 VIRTUAL_ENV 与 PATH，PATH 在任务或当前进程的 PATH 之前加入虚拟环境的 bin 目录
+func (t *Task) environ(envDir string) []string {
+	var env []string
+	switch t.EnvInherit {
+	case InheritAll:
+		env = os.Environ()
+	case InheritAllowlist:
+		allowlist := t.EnvAllowlist
+		if allowlist == nil {
+			allowlist = DefaultEnvAllowlist
+		}
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			if envAllowed(allowlist, name) {
+				env = append(env, kv)
+			}
+		}
+	}
+
+	// 标准输出是管道时 Python 默认整块缓冲，关闭缓冲后输出才能实时到达 OutputStream；任务可以覆盖
+	env = append(env, "PYTHONUNBUFFERED=1")
+
+	path := os.Getenv("PATH")
+	for _, vars := range []map[string]string{t.Env, t.SecretEnv} {
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == "PATH" {
+				path = vars[name]
+				continue
+			}
+			env = append(env, name+"="+vars[name])
+		}
+	}
+
+	// 重复的变量以最后出现的为准
+	return append(env,
+		"VIRTUAL_ENV="+envDir,
+		"PATH="+filepath.Join(envDir, "bin")+string(os.PathListSeparator)+path,
+	)
+}
+
+// scriptDir 返回脚本的工作目录：任务指定的 WorkDir；声明了产物时为临时工作目录 workDir，
+// 以相对路径写入的文件即可被收集；否则返回空字符串，沿用当前进程的工作目录
+func (t *Task) scriptDir(workDir string) string {
+	if t.WorkDir != "" {
+		if abs, err := filepath.Abs(t.WorkDir); err == nil {
+			return abs
+		}
+		return t.WorkDir
+	}
+	if len(t.Outputs) > 0 {
+		return workDir
+	}
+	return ""
+}
+
+// envAllowed 判断变量名是否在允许列表中
+func envAllowed(allowlist []string, name string) bool {
+	for _, pattern := range allowlist {
+		if pattern == name {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretRegistry 任务声明的敏感值，按任务ID登记，库写入日志前将其替换为 RedactedValue
+// 任务执行结束后其值仍然保留，调用方记录完日志后通过 ReleaseTaskSecrets 释放
+type secretRegistry struct {
+	mu    sync.RWMutex
+	tasks map[string]*taskSecrets
+	all   *strings.Replacer // 替换所有已登记的敏感值，没有登记时为 nil
+}
+
+// taskSecrets 同一任务ID下登记的敏感值
+type taskSecrets struct {
+	refs     int  // 正在执行的次数
+	released bool // 执行期间调用了 ReleaseTaskSecrets，执行结束后即释放
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// secrets 所有任务共享的敏感值注册表
+var secrets = &secretRegistry{tasks: make(map[string]*taskSecrets)}
+
+// register 登记任务 SecretEnv 中的值，返回的函数在执行结束时调用
+// 同一任务可以被多次登记（例如执行器与 GopoolExecutor 各登记一次），执行结束后其值保留到 ReleaseTaskSecrets 被调用
+func (r *secretRegistry) register(task *Task) func() {
+	if len(task.SecretEnv) == 0 {
+		return func() {}
+	}
+	id := task.ID
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := r.tasks[id]
+	if entry == nil {
+		entry = &taskSecrets{values: make(map[string]bool)}
+		r.tasks[id] = entry
+	}
+	entry.refs++
+	entry.released = false
+	changed := false
+	for _, value := range task.SecretEnv {
+		if value != "" && !entry.values[value] {
+			entry.values[value] = true
+			changed = true
+		}
+	}
+	if changed {
+		entry.replacer = newRedactor(entry.values)
+		r.rebuildLocked()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { r.finish(id) })
+	}
+}
+
+// finish 结束 register 登记的一次执行，执行期间已被释放的任务在最后一次执行结束时释放
+func (r *secretRegistry) finish(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.tasks[id]
+	if entry == nil {
+		return
+	}
+	if entry.refs--; entry.refs > 0 || !entry.released {
+		return
+	}
+	delete(r.tasks, id)
+	r.rebuildLocked()
+}
+
+// release 释放任务的敏感值，任务正在执行时推迟到执行结束
+func (r *secretRegistry) release(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.tasks[id]
+	if entry == nil {
+		return
+	}
+	if entry.refs > 0 {
+		entry.released = true
+		return
+	}
+	delete(r.tasks, id)
+	r.rebuildLocked()
+}
+
+// rebuildLocked 重新生成替换所有已登记敏感值的 Replacer，调用方须持有写锁
+func (r *secretRegistry) rebuildLocked() {
+	values := make(map[string]bool)
+	for _, entry := range r.tasks {
+		for value := range entry.values {
+			values[value] = true
+		}
+	}
+	r.all = newRedactor(values)
+}
+
+// newRedactor 返回将 values 替换为 RedactedValue 的 Replacer，values 为空时返回 nil
+func newRedactor(values map[string]bool) *strings.Replacer {
+	if len(values) == 0 {
+		return nil
+	}
+	// 较长的值优先替换，避免只替换其中一部分
+	sorted := make([]string, 0, len(values))
+	for value := range values {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		pairs = append(pairs, value, RedactedValue)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// RedactSecrets 将 s 中出现的、所有已登记且尚未释放的任务的敏感环境变量值替换为 RedactedValue
+// 只知道任务ID时应使用 RedactTaskSecrets，避免一个任务的日志被其他任务的敏感值影响
+func RedactSecrets(s string) string {
+	secrets.mu.RLock()
+	replacer := secrets.all
+	secrets.mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// RedactTaskSecrets 将 s 中出现的任务 taskID 的敏感环境变量值替换为 RedactedValue
+// 任务从开始执行起登记，直到调用 ReleaseTaskSecrets；库写入的任务输出与错误都经过该函数处理，自定义的 Logger 也可以使用它
+func RedactTaskSecrets(taskID, s string) string {
+	secrets.mu.RLock()
+	var replacer *strings.Replacer
+	if entry := secrets.tasks[taskID]; entry != nil {
+		replacer = entry.replacer
+	}
+	secrets.mu.RUnlock()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// ReleaseTaskSecrets 释放任务 taskID 登记的敏感值，之后 RedactTaskSecrets 不再对其脱敏
+// 任务执行结束、记录完其输出与错误后调用；任务仍在执行时推迟到执行结束后释放
+func ReleaseTaskSecrets(taskID string) {
+	secrets.release(taskID)
+}
@@ -0,0 +1,180 @@
+package pyExecuter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TaskStatus 通过 Submit 提交的任务所处的阶段
+type TaskStatus int
+
+const (
+	TaskQueued    TaskStatus = iota // 在队列中等待执行（包括失败后等待重试）
+	TaskRunning                     // 正在执行
+	TaskSucceeded                   // 执行成功
+	TaskFailed                      // 执行失败且没有剩余的重试次数
+	TaskCancelled                   // 执行前或执行中被取消
+)
+
+// String 返回状态的名称
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskQueued:
+		return "queued"
+	case TaskRunning:
+		return "running"
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskFailed:
+		return "failed"
+	case TaskCancelled:
+		return "cancelled"
+	}
+	return fmt.Sprintf("TaskStatus(%d)", int(s))
+}
+
+// Finished 返回任务是否已经结束
+func (s TaskStatus) Finished() bool {
+	return s >= TaskSucceeded
+}
+
+// TaskHandle 通过 Submit 提交的任务的句柄，用于等待、查询与取消任务
+// 任务只在最终结束时（成功、重试耗尽或被取消）完成，失败后重新排队的尝试不会完成句柄
+type TaskHandle struct {
+	Task *Task // 提交的任务
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	status TaskStatus
+	result Result
+}
+
+// newTaskHandle 创建任务句柄，ctx 结束时任务被取消
+func newTaskHandle(ctx context.Context, task *Task) *TaskHandle {
+	ctx, cancel := context.WithCancel(ctx)
+	return &TaskHandle{
+		Task:   task,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+}
+
+// Done 返回任务结束后关闭的 channel
+func (h *TaskHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Status 返回任务当前的状态
+func (h *TaskHandle) Status() TaskStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Cancel 取消任务：排队中的任务不再执行，正在执行的任务被终止，已结束的任务不受影响
+func (h *TaskHandle) Cancel() {
+	h.cancel()
+}
+
+// Result 返回任务的结果，任务尚未结束时第二个返回值为 false
+func (h *TaskHandle) Result() (Result, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result, h.status.Finished()
+}
+
+// Wait 等待任务结束并返回其结果与错误；ctx 先结束时返回 ctx 的错误，任务不受影响
+func (h *TaskHandle) Wait(ctx context.Context) (Result, error) {
+	select {
+	case <-h.done:
+		res, _ := h.Result()
+		return res, res.Error
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// start 将排队中的任务标记为正在执行，任务已经结束时返回 false
+func (h *TaskHandle) start() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status != TaskQueued {
+		return false
+	}
+	h.status = TaskRunning
+	return true
+}
+
+// requeue 将失败后重新排队的任务标记为排队中
+func (h *TaskHandle) requeue() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status == TaskRunning {
+		h.status = TaskQueued
+	}
+}
+
+// Release 释放任务的敏感环境变量值，应在记录完任务的日志后调用，见 ReleaseTaskSecrets
+func (h *TaskHandle) Release() {
+	ReleaseTaskSecrets(h.Task.ID)
+}
+
+// finish 以最终结果完成正在执行的任务
+func (h *TaskHandle) finish(res Result) {
+	h.finishIf(TaskRunning, res)
+}
+
+// finishIf 在任务处于 status 状态时以最终结果完成任务，返回是否完成
+func (h *TaskHandle) finishIf(status TaskStatus, res Result) bool {
+	h.mu.Lock()
+	if h.status != status || h.status.Finished() {
+		h.mu.Unlock()
+		return false
+	}
+	h.status = res.Status
+	h.result = res
+	h.mu.Unlock()
+
+	close(h.done)
+	h.cancel()
+	return true
+}
+
+// WaitAll 等待所有任务结束，返回与 handles 顺序对应的结果
+// 任一任务失败时立即返回该错误，此时尚未结束的任务结果为零值且不会被取消
+func WaitAll(ctx context.Context, handles []*TaskHandle) ([]Result, error) {
+	results := make([]Result, len(handles))
+	type finished struct {
+		index int
+		res   Result
+		err   error
+	}
+	ch := make(chan finished, len(handles))
+	for i, h := range handles {
+		go func(i int, h *TaskHandle) {
+			select {
+			case <-h.done:
+				res, _ := h.Result()
+				ch <- finished{i, res, res.Error}
+			case <-ctx.Done():
+			}
+		}(i, h)
+	}
+
+	for range handles {
+		select {
+		case f := <-ch:
+			results[f.index] = f.res
+			if f.err != nil {
+				return results, f.err
+			}
+		case <-ctx.Done():
+			return results, ctx.Err()
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,330 @@
+package pyExecuter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxInputBytes 单项输入（标准输入、负载或单个文件）默认的最大长度
+const DefaultMaxInputBytes = 256 << 20
+
+// DefaultMaxTotalInputBytes 一个任务所有输入之和默认的最大长度
+const DefaultMaxTotalInputBytes = 1 << 30
+
+// inputDirEnv 向脚本暴露输入文件所在目录的环境变量，由辅助模块 pyexecuter 使用
+const inputDirEnv = "PYEXECUTER_INPUT_DIR"
+
+// payloadPathEnv 向脚本暴露负载文件路径的环境变量，由辅助模块 pyexecuter 使用
+const payloadPathEnv = "PYEXECUTER_PAYLOAD_PATH"
+
+// stdinFileName 标准输入在临时目录中的文件名
+const stdinFileName = "_pyexecuter_stdin"
+
+// payloadFileName 负载在临时目录中的文件名
+const payloadFileName = "_pyexecuter_payload.json"
+
+// ErrInputTooLarge 输入超过了大小限制
+var ErrInputTooLarge = errors.New("input too large")
+
+// ErrChecksumMismatch 输入的摘要与期望值不一致
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// TaskInput 任务的输入数据，在脚本运行前写入临时工作目录
+type TaskInput struct {
+	Stdin       []byte    // 标准输入的内容
+	StdinReader io.Reader `json:"-"` // 标准输入的数据源，设置后优先于 Stdin；只能读取一次，任务重试时不会重放
+	StdinSHA256 string    // 标准输入的十六进制 SHA-256 摘要，为空时不校验
+
+	Payload       interface{} // JSON 负载，脚本通过 pyexecuter.payload() 读取；json.RawMessage 按原样传递
+	PayloadSHA256 string      // 负载编码后的 JSON 的十六进制 SHA-256 摘要，为空时不校验
+
+	Files []InputFile // 写入工作目录的输入文件，脚本通过 pyexecuter.input_path(name) 取得路径
+}
+
+// InputFile 任务的一个输入文件
+type InputFile struct {
+	Name   string // 相对工作目录的文件名，可以包含子目录，但不能是绝对路径或指向目录之外
+	Data   []byte // 文件内容
+	Path   string // 宿主机上的源文件，Data 为空时复制该文件的内容
+	SHA256 string // 内容的十六进制 SHA-256 摘要，为空时不校验
+}
+
+// InputLimits 任务输入的大小限制（字节），0 表示使用默认值，负数表示不限制
+type InputLimits struct {
+	MaxStdinBytes   int64 // 标准输入的最大长度，默认 DefaultMaxInputBytes
+	MaxPayloadBytes int64 // 负载的最大长度，默认 DefaultMaxInputBytes
+	MaxFileBytes    int64 // 单个输入文件的最大长度，默认 DefaultMaxInputBytes
+	MaxTotalBytes   int64 // 所有输入之和的最大长度，默认 DefaultMaxTotalInputBytes
+}
+
+// InputError 任务输入无效的错误，与脚本执行失败区分
+type InputError struct {
+	Input string // 出错的输入，例如 "stdin"、"payload" 或 "file data.csv"
+	Err   error  // 底层错误，可通过 errors.Is 判断 ErrInputTooLarge 与 ErrChecksumMismatch
+}
+
+// Error 实现 error 接口
+func (e *InputError) Error() string {
+	return fmt.Sprintf("invalid task input %s: %v", e.Input, e.Err)
+}
+
+// Unwrap 返回底层错误
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// inputLimit 返回限制的实际值，不限制时返回最大的 int64
+func inputLimit(value, def int64) int64 {
+	switch {
+	case value == 0:
+		return def
+	case value < 0:
+		return 1<<63 - 1
+	}
+	return value
+}
+
+// validate 在不读取数据源的情况下校验输入：文件名、内存中数据的大小与摘要，以及源文件的大小
+// 通过 StdinReader 与 Path 提供的内容在写入工作目录时才校验摘要
+func (in *TaskInput) validate(limits InputLimits) error {
+	if in == nil {
+		return nil
+	}
+	total := int64(0)
+	check := func(input string, size, max int64) error {
+		total += size
+		if size > max {
+			return &InputError{Input: input, Err: fmt.Errorf("%w: %d bytes exceeds %d bytes", ErrInputTooLarge, size, max)}
+		}
+		if maxTotal := inputLimit(limits.MaxTotalBytes, DefaultMaxTotalInputBytes); total > maxTotal {
+			return &InputError{Input: input, Err: fmt.Errorf("%w: total input exceeds %d bytes", ErrInputTooLarge, maxTotal)}
+		}
+		return nil
+	}
+
+	if in.StdinReader == nil {
+		if err := check("stdin", int64(len(in.Stdin)), inputLimit(limits.MaxStdinBytes, DefaultMaxInputBytes)); err != nil {
+			return err
+		}
+		if err := verifyChecksum("stdin", in.Stdin, in.StdinSHA256); err != nil {
+			return err
+		}
+	}
+
+	payload, err := in.encodePayload()
+	if err != nil {
+		return err
+	}
+	if err := check("payload", int64(len(payload)), inputLimit(limits.MaxPayloadBytes, DefaultMaxInputBytes)); err != nil {
+		return err
+	}
+	if payload != nil {
+		if err := verifyChecksum("payload", payload, in.PayloadSHA256); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool, len(in.Files))
+	for _, file := range in.Files {
+		input := "file " + file.Name
+		name, err := inputFileName(file.Name)
+		if err != nil {
+			return &InputError{Input: input, Err: err}
+		}
+		if seen[name] {
+			return &InputError{Input: input, Err: errors.New("duplicate file name")}
+		}
+		seen[name] = true
+
+		size := int64(len(file.Data))
+		if file.Data == nil && file.Path != "" {
+			info, err := os.Stat(file.Path)
+			if err != nil {
+				return &InputError{Input: input, Err: err}
+			}
+			if !info.Mode().IsRegular() {
+				return &InputError{Input: input, Err: fmt.Errorf("%s is not a regular file", file.Path)}
+			}
+			size = info.Size()
+		}
+		if err := check(input, size, inputLimit(limits.MaxFileBytes, DefaultMaxInputBytes)); err != nil {
+			return err
+		}
+		if file.Data != nil {
+			if err := verifyChecksum(input, file.Data, file.SHA256); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// materialize 将输入写入工作目录 dir，返回标准输入文件的路径，没有标准输入时返回空字符串
+// 写入时再次校验大小与摘要，因此数据源在校验之后发生变化也能被发现
+func (in *TaskInput) materialize(dir string, limits InputLimits) (string, error) {
+	if in == nil {
+		return "", nil
+	}
+	maxTotal := inputLimit(limits.MaxTotalBytes, DefaultMaxTotalInputBytes)
+	written := int64(0)
+	write := func(input, path string, r io.Reader, max int64, checksum string) error {
+		bound := max
+		if maxTotal-written < bound {
+			bound = maxTotal - written
+		}
+		n, err := writeInputFile(input, path, r, bound, checksum)
+		written += n
+		if errors.Is(err, ErrInputTooLarge) && bound < max {
+			return &InputError{Input: input, Err: fmt.Errorf("%w: total input exceeds %d bytes", ErrInputTooLarge, maxTotal)}
+		}
+		return err
+	}
+
+	stdinPath := ""
+	if in.StdinReader != nil || in.Stdin != nil {
+		src := in.StdinReader
+		if src == nil {
+			src = bytes.NewReader(in.Stdin)
+		}
+		stdinPath = filepath.Join(dir, stdinFileName)
+		if err := write("stdin", stdinPath, src, inputLimit(limits.MaxStdinBytes, DefaultMaxInputBytes), in.StdinSHA256); err != nil {
+			return "", err
+		}
+	}
+
+	payload, err := in.encodePayload()
+	if err != nil {
+		return "", err
+	}
+	if payload != nil {
+		if err := write("payload", payloadPath(dir), bytes.NewReader(payload), inputLimit(limits.MaxPayloadBytes, DefaultMaxInputBytes), in.PayloadSHA256); err != nil {
+			return "", err
+		}
+	}
+
+	for _, file := range in.Files {
+		input := "file " + file.Name
+		name, err := inputFileName(file.Name)
+		if err != nil {
+			return "", &InputError{Input: input, Err: err}
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", &InputError{Input: input, Err: err}
+		}
+
+		var src io.Reader = bytes.NewReader(file.Data)
+		if file.Data == nil && file.Path != "" {
+			f, err := os.Open(file.Path)
+			if err != nil {
+				return "", &InputError{Input: input, Err: err}
+			}
+			defer f.Close()
+			src = f
+		}
+		if err := write(input, path, src, inputLimit(limits.MaxFileBytes, DefaultMaxInputBytes), file.SHA256); err != nil {
+			return "", err
+		}
+	}
+	return stdinPath, nil
+}
+
+// encodePayload 返回负载的 JSON 编码，没有负载时返回 nil
+func (in *TaskInput) encodePayload() ([]byte, error) {
+	if raw, ok := in.Payload.(json.RawMessage); ok {
+		if len(raw) == 0 {
+			return nil, nil
+		}
+		if !json.Valid(raw) {
+			return nil, &InputError{Input: "payload", Err: errors.New("invalid JSON")}
+		}
+		return raw, nil
+	}
+	if in.Payload == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(in.Payload)
+	if err != nil {
+		return nil, &InputError{Input: "payload", Err: err}
+	}
+	return data, nil
+}
+
+// writeInputFile 将 r 的内容写入 path，同时检查长度不超过 max 并计算摘要，返回写入的字节数
+func writeInputFile(input, path string, r io.Reader, max int64, checksum string) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, &InputError{Input: input, Err: err}
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r, max+1))
+	if err != nil {
+		return n, &InputError{Input: input, Err: err}
+	}
+	if n > max {
+		return n, &InputError{Input: input, Err: fmt.Errorf("%w: exceeds %d bytes", ErrInputTooLarge, max)}
+	}
+	if err := compareChecksum(input, hash.Sum(nil), checksum); err != nil {
+		return n, err
+	}
+	if err := f.Close(); err != nil {
+		return n, &InputError{Input: input, Err: err}
+	}
+	return n, nil
+}
+
+// verifyChecksum 校验 data 的 SHA-256 摘要，checksum 为空时不校验
+func verifyChecksum(input string, data []byte, checksum string) error {
+	sum := sha256.Sum256(data)
+	return compareChecksum(input, sum[:], checksum)
+}
+
+// compareChecksum 比较摘要与期望的十六进制摘要，checksum 为空时不校验
+func compareChecksum(input string, sum []byte, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	if got := hex.EncodeToString(sum); !strings.EqualFold(got, checksum) {
+		return &InputError{Input: input, Err: fmt.Errorf("%w: got sha256 %s, want %s", ErrChecksumMismatch, got, checksum)}
+	}
+	return nil
+}
+
+// inputFileName 校验并规范化输入文件名，拒绝绝对路径、指向工作目录之外的路径以及执行器保留的文件名
+func inputFileName(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty file name")
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", errors.New("file name must be relative")
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.New("file name escapes the working directory")
+	}
+	first := strings.SplitN(filepath.ToSlash(clean), "/", 2)[0]
+	if reservedFileName(first) {
+		return "", fmt.Errorf("file name %s is reserved", first)
+	}
+	return clean, nil
+}
+
+// reservedFileName 判断工作目录中的顶层文件名是否由执行器使用
+func reservedFileName(name string) bool {
+	return strings.HasPrefix(name, "_pyexecuter") || name == "script.py" || name == helperFileName || name == ".rootfs" || name == "__pycache__"
+}
+
+// payloadPath 返回工作目录中负载文件的路径
+func payloadPath(workDir string) string {
+	return filepath.Join(workDir, payloadFileName)
+}
@@ -49,19 +49,19 @@ if err := f.writeToFile(fmt.Sprintf("Task %s started at %s\n", taskID, startTime
 return nil
 }
 
-// LogTaskEnd 记录任务结束
+// LogTaskEnd 记录任务结束，输出与错误中的敏感环境变量值会被脱敏，应在调用 ReleaseTaskSecrets 之前记录
 func (f *FileLogger) LogTaskEnd(taskID string, endTime time.Time, output string, err error) error {
 	taskLogs := f.logs[taskID]
 taskLog := &taskLogs[len(taskLogs)-1]
 taskLog.EndTime = endTime
-taskLog.Output = output
+taskLog.Output = RedactTaskSecrets(taskID, output)
 if err != nil {
-    taskLog.Error = err.Error()
+    taskLog.Error = RedactTaskSecrets(taskID, err.Error())
 }
 f.logs[taskID] = taskLogs  // 确保更新后的日志写回到映射中
-	logMessage := fmt.Sprintf("Task %s ended at %s with output: %s", taskID, endTime, output)
+	logMessage := fmt.Sprintf("Task %s ended at %s with output: %s", taskID, endTime, taskLog.Output)
 if err != nil {
-    logMessage += fmt.Sprintf(" and error: %v", err)
+    logMessage += fmt.Sprintf(" and error: %s", taskLog.Error)
 }
 if err := f.writeToFile(logMessage + "\n"); err != nil {
     return fmt.Errorf("failed to log task end: %v", err)
@@ -6,6 +6,14 @@ import (
 	"time"
 )
 
+// 执行器报告给 TaskMonitor 的任务状态
+const (
+	MonitorStatusRunning   = "Running"   // 正在执行
+	MonitorStatusCompleted = "Completed" // 执行成功
+	MonitorStatusFailed    = "Failed"    // 执行失败且没有剩余的重试次数
+	MonitorStatusCancelled = "Cancelled" // 被取消
+)
+
 // TaskMonitoring 任务监控结构体
 type TaskMonitoring struct {
 	TaskID        string        // 任务唯一标识
@@ -59,7 +67,7 @@ func (m *BasicTaskMonitor) StartMonitoring(taskID string) error {
 	m.monitorData[taskID] = &TaskMonitoring{
 		TaskID:    taskID,
 		StartTime: time.Now(),
-		Status:    "Running",
+		Status:    MonitorStatusRunning,
 		ResourceUsage: ResourceUsage{}, // 初始化资源使用情况
 	}
 	return nil
@@ -75,7 +83,7 @@ func (m *BasicTaskMonitor) StopMonitoring(taskID string) error {
 		return fmt.Errorf("task %s is not being monitored", taskID)
 	}
 	monitor.EndTime = time.Now()
-	monitor.Status = "Completed"
+	monitor.Status = MonitorStatusCompleted
 	return nil
 }
 
@@ -108,18 +116,18 @@ func (m *BasicTaskMonitor) UpdateTaskStatus(taskID string, status string, usage
 // periodicallyUpdateResourceUsage 定期更新所有任务的资源使用情况
 func (m *BasicTaskMonitor) periodicallyUpdateResourceUsage() {
 	for range m.updateTicker.C {
-		m.mu.RLock()
-		for taskID := range m.monitorData {
+		// 持有写锁直接更新，在读锁内调用 UpdateTaskStatus 会导致死锁
+		m.mu.Lock()
+		for _, monitor := range m.monitorData {
 			// 这里应该实现实际的资源使用情况更新逻辑
 			// 例如，调用系统API获取CPU、内存、磁盘和网络使用情况
-			usage := ResourceUsage{
+			monitor.ResourceUsage = ResourceUsage{
 				CPUUsage:     0.5,  // 示例值
 				MemoryUsage:  1024, // 示例值
 				DiskUsage:    2048, // 示例值
 				NetworkUsage: 512,  // 示例值
 			}
-			m.UpdateTaskStatus(taskID, m.monitorData[taskID].Status, usage)
 		}
-		m.mu.RUnlock()
+		m.mu.Unlock()
 	}
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package pyExecuter
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"sync"
@@ -11,7 +12,8 @@ type TaskQueue struct {
 	tasks        []*Task
 	maxCapacity  int
 	mu           sync.RWMutex
-	priorityMode string // "FIFO" or "LIFO"
+	priorityMode string        // "FIFO" or "LIFO"
+	ready        chan struct{} // 有 WaitTask 等待时创建，加入任务时关闭以唤醒等待者
 }
 
 // NewTaskQueue 创建一个TaskQueue实例
@@ -42,6 +44,10 @@ func (q *TaskQueue) AddTask(task *Task) error {
 	q.tasks = append(q.tasks, nil)
 	copy(q.tasks[index+1:], q.tasks[index:])
 	q.tasks[index] = task
+	if q.ready != nil {
+		close(q.ready)
+		q.ready = nil
+	}
 	return nil
 }
 
@@ -65,6 +71,40 @@ func (q *TaskQueue) GetTask() (*Task, error) {
 	return task, nil
 }
 
+// WaitTask 获取一个任务，队列为空时阻塞直到有任务加入或 ctx 结束
+func (q *TaskQueue) WaitTask(ctx context.Context) (*Task, error) {
+	for {
+		if task, err := q.GetTask(); err == nil {
+			return task, nil
+		}
+		// 其他等待者可能先取走了任务，被唤醒后需要重新获取
+		if err := q.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait 阻塞直到队列中有任务或 ctx 结束（内部方法）
+func (q *TaskQueue) wait(ctx context.Context) error {
+	q.mu.Lock()
+	if len(q.tasks) > 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.ready == nil {
+		q.ready = make(chan struct{})
+	}
+	ready := q.ready
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Size 返回队列中的任务数量
 func (q *TaskQueue) Size() int {
 	q.mu.RLock()
@@ -82,4 +122,62 @@ func (q *TaskQueue) GetTaskByID(taskID string) (*Task, error) {
 		}
 	}
 	return nil, fmt.Errorf("task with ID %s not found", taskID)
+}
+
+// RemoveTask 从队列中移除指定ID的第一个任务并返回它
+func (q *TaskQueue) RemoveTask(taskID string) (*Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, task := range q.tasks {
+		if task.ID == taskID {
+			q.tasks = append(q.tasks[:i], q.tasks[i+1:]...)
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("task with ID %s not found", taskID)
+}
+
+// removeTask 从队列中移除指定的任务，返回任务是否在队列中（内部方法）
+func (q *TaskQueue) removeTask(task *Task) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, t := range q.tasks {
+		if t == task {
+			q.tasks = append(q.tasks[:i], q.tasks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// take 按出队顺序取出第一个 accept 返回 true 的任务，没有时返回 nil（内部方法）
+func (q *TaskQueue) take(accept func(*Task) bool) *Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.tasks)
+	for k := 0; k < n; k++ {
+		i := k
+		if q.priorityMode == "LIFO" {
+			i = n - 1 - k
+		}
+		if task := q.tasks[i]; accept(task) {
+			q.tasks = append(q.tasks[:i], q.tasks[i+1:]...)
+			return task
+		}
+	}
+	return nil
+}
+
+// added 返回下一个任务加入队列时关闭的 channel（内部方法）
+func (q *TaskQueue) added() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.ready == nil {
+		q.ready = make(chan struct{})
+	}
+	return q.ready
 }
\ No newline at end of file
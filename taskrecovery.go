@@ -30,6 +30,12 @@ type Recovery interface {
 	LoadStates() error                                 // 从磁盘加载所有状态
 }
 
+// PendingTaskStore 保存未完成任务的可选接口，实现了它的 Recovery 会在 GopoolExecutor.Shutdown 时保存未完成的任务
+type PendingTaskStore interface {
+	SaveTasks(tasks []*Task) error  // 保存未完成的任务，覆盖之前保存的任务
+	PendingTasks() ([]*Task, error) // 返回保存的未完成任务
+}
+
 // NewTaskRecovery 创建 TaskRecovery 实例
 func NewTaskRecovery(storageDir string) *TaskRecovery {
 	return &TaskRecovery{
@@ -117,3 +123,40 @@ func (r *TaskRecovery) LoadStates() error {
 
 	return nil
 }
+
+// SaveTasks 保存执行器关闭时未完成的任务，覆盖之前保存的任务列表
+// 任务的 SecretEnv 不会写入文件，文件只对当前用户可读写
+func (r *TaskRecovery) SaveTasks(tasks []*Task) error {
+	if tasks == nil {
+		tasks = []*Task{}
+	}
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending tasks: %v", err)
+	}
+
+	filePath := filepath.Join(r.storageDir, "pending_tasks.json")
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pending tasks to file: %v", err)
+	}
+	return nil
+}
+
+// PendingTasks 返回通过 SaveTasks 保存的任务，重启后重新提交即可继续执行
+// 任务的 SecretEnv、OnCompletion 回调与 Input.StdinReader 没有保存，需要在重新提交前设置
+func (r *TaskRecovery) PendingTasks() ([]*Task, error) {
+	filePath := filepath.Join(r.storageDir, "pending_tasks.json")
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read pending tasks from file: %v", err)
+	}
+
+	var tasks []*Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending tasks: %v", err)
+	}
+	return tasks, nil
+}
@@ -1,7 +1,22 @@
 package pyExecuter_test
 
 import (
+	"archive/zip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -9,6 +24,12 @@ import (
 	"github.com/tomllt/pyExecuter"
 )
 
+func TestMain(m *testing.M) {
+	// 沙箱与 seccomp 通过重新执行测试程序进入启动器
+	pyExecuter.RunLauncher()
+	os.Exit(m.Run())
+}
+
 func TestGopoolExecutor(t *testing.T) {
 	// 创建任务队列
 	queue := pyExecuter.NewTaskQueue(100, "FIFO")
@@ -67,6 +88,1744 @@ print("Hello from Python!")
 	assert.Contains(t, output, "Hello from Python!")
 }
 
+func TestPythonExecutorRun(t *testing.T) {
+	executor := &pyExecuter.SecurePythonExecutor{}
+
+	err := executor.SetupEnvironment(t.TempDir())
+	assert.NoError(t, err)
+
+	// 标准输出与标准错误应分开收集，失败时仍保留输出
+	script := `
+import sys
+print("to stdout")
+print("to stderr", file=sys.stderr)
+sys.exit(3)
+`
+	res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 5 * time.Second})
+	assert.Error(t, err)
+	assert.NotNil(t, res)
+	assert.Equal(t, "to stdout\n", res.Stdout)
+	assert.Equal(t, "to stderr\n", res.Stderr)
+	assert.Equal(t, 3, res.ExitCode)
+	assert.False(t, res.TimedOut)
+
+	// 超时应被标记
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "import time\ntime.sleep(10)", Timeout: 500 * time.Millisecond})
+	assert.Error(t, err)
+	assert.True(t, res.TimedOut)
+	assert.NotZero(t, res.Signal)
+}
+
+func TestPythonExecutorStream(t *testing.T) {
+	executor := &pyExecuter.SecurePythonExecutor{}
+
+	err := executor.SetupEnvironment(t.TempDir())
+	assert.NoError(t, err)
+
+	stream := pyExecuter.NewOutputStream(pyExecuter.StreamLines, 0)
+	chunks, unsubscribe := stream.SubscribeChan()
+	defer unsubscribe()
+
+	// 慢速订阅者不应阻塞脚本执行
+	blocked := make(chan struct{})
+	defer close(blocked)
+	stream.Subscribe(func(chunk pyExecuter.OutputChunk) { <-blocked })
+
+	// 不刷新缓冲区的输出也在脚本结束前到达
+	script := `
+import sys, time
+print("first")
+print("oops", file=sys.stderr)
+time.sleep(1)
+for i in range(5000):
+    print(i)
+`
+	task := &pyExecuter.Task{ID: "stream_task", Script: script, Timeout: 10 * time.Second}
+	firstArrived := make(chan time.Time, 1)
+	stream.Subscribe(func(chunk pyExecuter.OutputChunk) {
+		if chunk.Stream != pyExecuter.StreamStdout {
+			return
+		}
+		select {
+		case firstArrived <- time.Now():
+		default:
+		}
+	})
+	res, err := executor.Stream(context.Background(), task, stream)
+	finished := time.Now()
+	assert.NoError(t, err)
+	assert.Contains(t, res.Stdout, "4999")
+	assert.Greater(t, stream.Dropped(), uint64(0))
+	select {
+	case first := <-firstArrived:
+		assert.Less(t, first.Add(500*time.Millisecond), finished)
+	default:
+		t.Fatal("no stdout streamed")
+	}
+
+	// 两个输出流之间不保证顺序，分别检查各自的第一块
+	firstByStream := make(map[string]pyExecuter.OutputChunk)
+	for len(firstByStream) < 2 {
+		chunk := <-chunks
+		assert.Equal(t, "stream_task", chunk.TaskID)
+		assert.False(t, chunk.Timestamp.IsZero())
+		if _, ok := firstByStream[chunk.Stream]; !ok {
+			firstByStream[chunk.Stream] = chunk
+		}
+	}
+	assert.Equal(t, "first\n", string(firstByStream[pyExecuter.StreamStdout].Data))
+	assert.Equal(t, "oops\n", string(firstByStream[pyExecuter.StreamStderr].Data))
+}
+
+func TestProcessGroupKill(t *testing.T) {
+	executor := &pyExecuter.SecurePythonExecutor{KillGracePeriod: time.Second}
+
+	err := executor.SetupEnvironment(t.TempDir())
+	assert.NoError(t, err)
+
+	// 超时后脚本先收到 SIGTERM，其派生的子进程也会被一并终止
+	script := `
+import signal, subprocess, sys, time
+child = subprocess.Popen(["sleep", "60"])
+print(child.pid, flush=True)
+def on_term(signum, frame):
+    print("terminating", flush=True)
+    sys.exit(0)
+signal.signal(signal.SIGTERM, on_term)
+time.sleep(60)
+`
+	res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: time.Second})
+	assert.Error(t, err)
+	assert.True(t, res.TimedOut)
+	assert.Contains(t, res.Stdout, "terminating")
+
+	pid, err := strconv.Atoi(strings.Fields(res.Stdout)[0])
+	assert.NoError(t, err)
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err == nil {
+		// 进程可能已成为等待回收的僵尸进程
+		assert.Contains(t, string(data), ") Z ")
+	}
+
+	// 忽略 SIGTERM 的脚本在宽限期后被强制终止
+	start := time.Now()
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{
+		Script:  "import signal, time\nsignal.signal(signal.SIGTERM, signal.SIG_IGN)\ntime.sleep(60)",
+		Timeout: 500 * time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, syscall.SIGKILL, res.Signal)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	// 正常退出时立即终止仍持有输出管道的后台子进程，不等待宽限期
+	executor.KillGracePeriod = 5 * time.Second
+	start = time.Now()
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{
+		Script:  "import subprocess\nsubprocess.Popen(['sleep', '60'])\nprint('done')",
+		Timeout: 30 * time.Second,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "done\n", res.Stdout)
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestResourceLimits(t *testing.T) {
+	executor := &pyExecuter.SecurePythonExecutor{}
+
+	err := executor.SetupEnvironment(t.TempDir())
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		script string
+		limits pyExecuter.ResourceLimits
+		want   string
+	}{
+		{"cpu", "while True:\n    pass", pyExecuter.ResourceLimits{CPUTime: time.Second}, pyExecuter.LimitCPU},
+		{"memory", "data = bytearray(1 << 30)", pyExecuter.ResourceLimits{AddressSpaceBytes: 256 << 20}, pyExecuter.LimitMemory},
+		{"open_files", "files = [open(__file__) for _ in range(100)]", pyExecuter.ResourceLimits{OpenFiles: 32}, pyExecuter.LimitOpenFiles},
+		{"file_size", "with open('big.bin', 'wb') as f:\n    f.write(b'x' * 4096)", pyExecuter.ResourceLimits{FileSizeBytes: 1024}, pyExecuter.LimitFileSize},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			limits := c.limits
+			script := "import os\nos.chdir(os.path.dirname(__file__))\n" + c.script
+			res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Limits: &limits, Timeout: 10 * time.Second})
+			assert.Error(t, err)
+			assert.Equal(t, c.want, res.LimitExceeded)
+		})
+	}
+
+	// 只能通过 cgroup 强制执行的限制在 cgroup v2 不可用时不会被忽略
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+		_, err = executor.Run(context.Background(), &pyExecuter.Task{
+			Script:  "print('unlimited')",
+			Limits:  &pyExecuter.ResourceLimits{Processes: 8},
+			Timeout: 10 * time.Second,
+		})
+		assert.ErrorIs(t, err, pyExecuter.ErrLimitUnenforceable)
+	}
+
+	// 未触发限制的脚本正常执行
+	res, err := executor.Run(context.Background(), &pyExecuter.Task{
+		Script:  "print('ok')",
+		Limits:  &pyExecuter.ResourceLimits{CPUTime: 5 * time.Second, OpenFiles: 64},
+		Timeout: 10 * time.Second,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "", res.LimitExceeded)
+}
+
+func TestSandboxExecutor(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandbox requires linux namespaces")
+	}
+	executor := pyExecuter.NewSandboxExecutor(pyExecuter.NewVenvManager(t.TempDir()), pyExecuter.SandboxConfig{})
+
+	script := `
+import os, socket, sys
+print("pid", os.getpid())
+print("uid", os.getuid())
+print("passwd", os.path.exists("/etc/passwd"))
+print("prefix", os.access(sys.prefix, os.W_OK))
+with open("scratch.txt", "w") as f:
+    f.write("ok")
+print("scratch", open("scratch.txt").read())
+try:
+    socket.create_connection(("1.1.1.1", 53), timeout=1)
+    print("network True")
+except OSError:
+    print("network False")
+`
+	res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 10 * time.Second})
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	if assert.NoError(t, err) {
+		assert.Contains(t, res.Stdout, "pid 1\n")
+		assert.Contains(t, res.Stdout, "uid 65534\n")
+		assert.Contains(t, res.Stdout, "passwd False\n")
+		assert.Contains(t, res.Stdout, "prefix False\n")
+		assert.Contains(t, res.Stdout, "scratch ok\n")
+		assert.Contains(t, res.Stdout, "network False\n")
+	}
+
+	// 沙箱执行器可以作为 GopoolExecutor 的执行器
+	queue := pyExecuter.NewTaskQueue(10, "FIFO")
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, queue)
+	gopoolExecutor.Executor = executor
+	result := gopoolExecutor.ExecuteTask(&pyExecuter.Task{ID: "sandboxed", Script: "import os\nprint(os.getpid())", Timeout: 10 * time.Second})
+	assert.NoError(t, result.Error)
+	assert.Equal(t, "1\n", result.Stdout)
+}
+
+func TestSeccompProfile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("seccomp requires linux")
+	}
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	executor := &pyExecuter.SecurePythonExecutor{Venvs: venvs, Seccomp: pyExecuter.DefaultSeccompProfile()}
+
+	// 默认规则拒绝 ptrace，进程由内核直接终止
+	script := `
+import ctypes, sys
+print("before", flush=True)
+print("tracing", file=sys.stderr, flush=True)
+ctypes.CDLL(None).ptrace(0, 0, 0, 0)
+print("after")
+`
+	res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 10 * time.Second})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "seccomp")
+	assert.True(t, res.SeccompViolation)
+	assert.Empty(t, res.SeccompSyscall)
+	assert.Equal(t, syscall.SIGSYS, res.Signal)
+	assert.Equal(t, "before\n", res.Stdout)
+	assert.Equal(t, "tracing\n", res.Stderr)
+
+	// 脚本无法通过替换 SIGSYS 处理器绕过 kill
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: `
+import ctypes, signal
+signal.signal(signal.SIGSYS, signal.SIG_IGN)
+ctypes.CDLL(None).ptrace(0, 0, 0, 0)
+print("after")
+`, Timeout: 10 * time.Second})
+	assert.Error(t, err)
+	assert.True(t, res.SeccompViolation)
+	assert.Empty(t, res.Stdout)
+
+	// trap 动作由启动脚本报告被拒绝的系统调用
+	trapping := &pyExecuter.SecurePythonExecutor{Venvs: venvs, Seccomp: &pyExecuter.SeccompProfile{
+		Syscalls: []pyExecuter.SeccompRule{{Names: []string{"ptrace"}, Action: pyExecuter.SeccompTrap}},
+	}}
+	res, err = trapping.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 10 * time.Second})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ptrace")
+	assert.True(t, res.SeccompViolation)
+	assert.Equal(t, "ptrace", res.SeccompSyscall)
+	assert.Equal(t, syscall.SIGSYS, res.Signal)
+	assert.Equal(t, "tracing\n", res.Stderr)
+
+	// 未违反规则的脚本正常执行
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "import socket\nsocket.socket().close()\nprint('ok')", Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", res.Stdout)
+	assert.False(t, res.SeccompViolation)
+
+	// clone3 返回 ENOSYS，线程与子进程退回到 clone 照常创建；clone 不能创建命名空间
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: `
+import ctypes, errno, platform, subprocess, threading
+libc = ctypes.CDLL(None, use_errno=True)
+print(libc.syscall(435, 0, 0) == -1 and ctypes.get_errno() == errno.ENOSYS)
+thread = threading.Thread(target=print, args=("thread",))
+thread.start()
+thread.join()
+print(subprocess.run(["echo", "child"], capture_output=True, text=True).stdout, end="")
+nr = {"x86_64": 56, "aarch64": 220}[platform.machine()]
+libc.syscall(nr, 0x10000000 | 17, 0, 0, 0, 0)
+print("after")
+`, Timeout: 10 * time.Second})
+	assert.Error(t, err)
+	assert.True(t, res.SeccompViolation)
+	assert.Equal(t, syscall.SIGSYS, res.Signal)
+	assert.Equal(t, "True\nthread\nchild\n", res.Stdout)
+
+	// 沙箱与 seccomp 可以同时启用，默认规则拒绝原始套接字
+	sandbox := pyExecuter.NewSandboxExecutor(venvs, pyExecuter.SandboxConfig{})
+	sandbox.Seccomp = pyExecuter.DefaultSeccompProfile()
+	res, err = sandbox.Run(context.Background(), &pyExecuter.Task{Script: "import socket\nsocket.socket(socket.AF_INET, socket.SOCK_RAW, 1)", Timeout: 10 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "operation not permitted") {
+		assert.Error(t, err)
+		assert.True(t, res.SeccompViolation)
+		assert.Equal(t, syscall.SIGSYS, res.Signal)
+		assert.Empty(t, res.Stderr)
+	}
+
+	// 从 JSON 文件加载的规则：mkdir 返回 EPERM
+	path := filepath.Join(t.TempDir(), "profile.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"defaultAction": "allow", "syscalls": [{"names": ["mkdir", "mkdirat"], "action": "errno"}]}`), 0644))
+	executor.Seccomp, err = pyExecuter.LoadSeccompProfile(path)
+	assert.NoError(t, err)
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: `
+import os
+try:
+    os.mkdir("denied")
+except PermissionError:
+    print("denied")
+`, Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	assert.Equal(t, "denied\n", res.Stdout)
+
+	// 白名单规则必须允许 execve
+	_, err = pyExecuter.ParseSeccompProfile([]byte(`{"defaultAction": "kill", "syscalls": [{"names": ["read"], "action": "allow"}]}`))
+	assert.Error(t, err)
+}
+
+func TestPooledPythonExecutor(t *testing.T) {
+	executor := pyExecuter.NewPooledPythonExecutor(pyExecuter.NewVenvManager(t.TempDir()), 1)
+	executor.MaxTasksPerWorker = 3
+	defer executor.Close()
+
+	run := func(script string) (*pyExecuter.ExecutionResult, error) {
+		return executor.Run(context.Background(), &pyExecuter.Task{Script: script, Args: []string{"a"}, Timeout: 5 * time.Second})
+	}
+
+	// 同一个 worker 依次执行任务，每个任务都有全新的命名空间
+	res, err := run("import os, sys\nx = 1\nprint(os.getpid(), sys.argv[1:])")
+	assert.NoError(t, err)
+	pid := strings.Fields(res.Stdout)[0]
+	assert.Contains(t, res.Stdout, "['a']")
+	res, err = run("import os\nprint(os.getpid(), 'x' in globals(), __name__)")
+	assert.NoError(t, err)
+	assert.Equal(t, pid+" False __main__\n", res.Stdout)
+
+	// 异常与退出码按解释器的规则返回
+	res, err = run("raise ValueError('boom')")
+	assert.Error(t, err)
+	assert.Equal(t, 1, res.ExitCode)
+	assert.Contains(t, res.Stderr, "ValueError: boom")
+
+	// 达到任务上限后 worker 被回收
+	res, err = run("import os, sys\nprint(os.getpid())\nsys.exit(0)")
+	assert.NoError(t, err)
+	assert.NotEqual(t, pid+"\n", res.Stdout)
+	pid = strings.TrimSpace(res.Stdout)
+
+	// 崩溃的 worker 被替换
+	res, err = run("import os\nos._exit(7)")
+	assert.Error(t, err)
+	assert.Equal(t, 7, res.ExitCode)
+	res, err = run("import os\nprint(os.getpid())")
+	assert.NoError(t, err)
+	assert.NotEqual(t, pid+"\n", res.Stdout)
+
+	// 超时的 worker 被终止，之后的任务不受影响
+	res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "import time\ntime.sleep(10)", Timeout: 500 * time.Millisecond})
+	assert.Error(t, err)
+	assert.True(t, res.TimedOut)
+	res, err = run("print('ok')")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", res.Stdout)
+
+	// 协议管道不在约定的描述符上，写入这些描述符不会伪造响应
+	res, err = run("import os\nfor fd in (3, 4):\n    try:\n        os.write(fd, b'\\0\\0\\0\\2{}')\n    except OSError:\n        pass\nprint('done')")
+	assert.NoError(t, err)
+	assert.Contains(t, res.Stdout, "done\n")
+	res, err = run("print('ok')")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", res.Stdout)
+
+	// 脚本的 __file__ 指向任务临时目录中的脚本文件，atexit 回调在任务结束时执行
+	res, err = run("import atexit, os\natexit.register(print, 'bye')\nprint(os.path.basename(__file__), os.path.isfile(__file__))")
+	assert.NoError(t, err)
+	assert.Equal(t, "script.py True\nbye\n", res.Stdout)
+
+	// 任务留下的进程随 worker 一起被终止，下一个任务在新的 worker 中执行
+	res, err = run("import os, subprocess\nchild = subprocess.Popen(['sleep', '30'])\nprint(os.getpid(), child.pid)")
+	assert.NoError(t, err)
+	pids := strings.Fields(res.Stdout)
+	if assert.Len(t, pids, 2) {
+		res, err = run(fmt.Sprintf(`
+import os, time
+def alive(pid):
+    try:
+        with open("/proc/%%d/stat" %% pid) as f:
+            return f.read().rsplit(")", 1)[1].split()[0] not in ("Z", "X")
+    except FileNotFoundError:
+        return False
+for _ in range(100):
+    if not alive(%s):
+        break
+    time.sleep(0.02)
+print(os.getpid(), alive(%s))`, pids[1], pids[1]))
+		assert.NoError(t, err)
+		assert.NotEqual(t, pids[0], strings.Fields(res.Stdout)[0])
+		assert.Contains(t, res.Stdout, " False\n")
+	}
+
+	// 任务留下的线程同样使 worker 被回收
+	res, err = run("import os, threading, time\nthreading.Thread(target=time.sleep, args=(30,), daemon=True).start()\nprint(os.getpid())")
+	assert.NoError(t, err)
+	pid = strings.TrimSpace(res.Stdout)
+	res, err = run("import os\nprint(os.getpid())")
+	assert.NoError(t, err)
+	assert.NotEqual(t, pid+"\n", res.Stdout)
+
+	// 输出在任务运行过程中即分发给订阅者
+	stream := pyExecuter.NewOutputStream(pyExecuter.StreamLines, 0)
+	firstArrived := make(chan time.Time, 1)
+	unsubscribe := stream.Subscribe(func(chunk pyExecuter.OutputChunk) {
+		if chunk.Stream == pyExecuter.StreamStdout && string(chunk.Data) == "first\n" {
+			firstArrived <- time.Now()
+		}
+	})
+	defer unsubscribe()
+	res, err = executor.Stream(context.Background(), &pyExecuter.Task{ID: "pooled_stream", Script: "import time\nprint('first')\ntime.sleep(1)\nprint('last')", Timeout: 5 * time.Second}, stream)
+	finished := time.Now()
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nlast\n", res.Stdout)
+	select {
+	case first := <-firstArrived:
+		assert.Less(t, first.Add(500*time.Millisecond), finished)
+	case <-time.After(time.Second):
+		t.Fatal("no stdout streamed")
+	}
+
+	executor.Close()
+	_, err = run("print('closed')")
+	assert.ErrorIs(t, err, pyExecuter.ErrExecutorClosed)
+}
+
+func TestResultChannel(t *testing.T) {
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+
+	script := `
+import sys, warnings
+import pyexecuter
+warnings.warn("noisy library")
+print("log line")
+pyexecuter.set_result({"answer": 42, "items": ["a", "b"]})
+`
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		// 返回值与输出分开返回
+		res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 5 * time.Second})
+		assert.NoError(t, err)
+		assert.Equal(t, "log line\n", res.Stdout)
+		assert.Contains(t, res.Stderr, "noisy library")
+		assert.Equal(t, map[string]interface{}{"answer": float64(42), "items": []interface{}{"a", "b"}}, res.Value)
+
+		var typed struct {
+			Answer int      `json:"answer"`
+			Items  []string `json:"items"`
+		}
+		assert.NoError(t, json.Unmarshal(res.RawValue, &typed))
+		assert.Equal(t, 42, typed.Answer)
+
+		// 未返回值
+		res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "print('nothing')", Timeout: 5 * time.Second})
+		assert.NoError(t, err)
+		assert.Nil(t, res.Value)
+
+		// 格式错误的数据返回 ResultDecodeError
+		res, err = executor.Run(context.Background(), &pyExecuter.Task{
+			Script:  "import os\nopen(os.environ['PYEXECUTER_RESULT_PATH'], 'w').write('{not json')\nprint('done')",
+			Timeout: 5 * time.Second,
+		})
+		var decodeErr *pyExecuter.ResultDecodeError
+		assert.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, "{not json", string(decodeErr.Data))
+		assert.Equal(t, "done\n", res.Stdout)
+
+		// NaN 与 Infinity 不是合法的 JSON，set_result 直接报错
+		res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "import pyexecuter\npyexecuter.set_result({'x': float('nan')})", Timeout: 5 * time.Second})
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &decodeErr))
+		assert.Contains(t, res.Stderr, "pyexecuter result is not valid JSON")
+		assert.Nil(t, res.Value)
+	}
+}
+
+func TestPythonError(t *testing.T) {
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+
+	script := `import json
+
+def parse(text):
+    return json.loads(text)
+
+def load():
+    try:
+        parse("{oops")
+    except ValueError as e:
+        raise RuntimeError("cannot load config") from e
+
+load()
+`
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 5 * time.Second})
+		assert.Error(t, err)
+		assert.Equal(t, 1, res.ExitCode)
+		assert.Contains(t, res.Stderr, "RuntimeError: cannot load config")
+		assert.Contains(t, res.Stderr, `File "<script>", line 10, in load`)
+		assert.NotContains(t, res.Stderr, "python_script_")
+
+		var pyErr *pyExecuter.PythonError
+		assert.True(t, errors.As(err, &pyErr))
+		assert.Equal(t, "RuntimeError", pyErr.Type)
+		assert.Equal(t, "cannot load config", pyErr.Message)
+		assert.Same(t, res.Exception, pyErr)
+
+		// 脚本帧映射回提交的源码，不包含执行器自身的帧
+		frame, ok := pyErr.ScriptFrame()
+		assert.True(t, ok)
+		assert.Equal(t, pyExecuter.PythonFrame{File: pyExecuter.ScriptFileName, Line: 10, Function: "load", Source: `raise RuntimeError("cannot load config") from e`}, frame)
+		assert.Equal(t, "<module>", pyErr.Frames[0].Function)
+		assert.Equal(t, pyExecuter.ScriptFileName, pyErr.Frames[0].File)
+		assert.NotContains(t, pyErr.Traceback, "bootstrap")
+
+		// 原因异常同样可以取得，且包含标准库中的帧
+		assert.NotNil(t, pyErr.Cause)
+		assert.Equal(t, "json.decoder.JSONDecodeError", pyErr.Cause.Type)
+		frame, _ = pyErr.Cause.ScriptFrame()
+		assert.Equal(t, "parse", frame.Function)
+		assert.NotEqual(t, pyExecuter.ScriptFileName, pyErr.Cause.Frames[len(pyErr.Cause.Frames)-1].File)
+
+		// 错误处理器返回的错误仍保留异常信息
+		handlerErr := pyExecuter.NewBasicErrorHandler(0, 0, nil).CaptureError("task", err)
+		assert.True(t, errors.As(handlerErr, &pyErr))
+
+		// 语法错误也以结构化形式返回
+		_, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "print('ok')\nif True print('bad')", Timeout: 5 * time.Second})
+		assert.True(t, errors.As(err, &pyErr))
+		assert.Equal(t, "SyntaxError", pyErr.Type)
+		frame, ok = pyErr.ScriptFrame()
+		assert.True(t, ok)
+		assert.Equal(t, 2, frame.Line)
+	}
+}
+
+func TestTaskInputs(t *testing.T) {
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+
+	source := filepath.Join(t.TempDir(), "source.txt")
+	assert.NoError(t, os.WriteFile(source, []byte("from disk"), 0600))
+	stdin := strings.Repeat("x", 2<<20)
+	sum := sha256.Sum256([]byte(stdin))
+
+	script := `import sys, pyexecuter
+data = sys.stdin.read()
+payload = pyexecuter.payload()
+with open(pyexecuter.input_path("data/config.txt")) as f:
+    config = f.read()
+with open(pyexecuter.input_path("source.txt")) as f:
+    source = f.read()
+pyexecuter.set_result({"stdin": len(data), "payload": payload, "config": config, "source": source})
+`
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 10 * time.Second, Input: &pyExecuter.TaskInput{
+			StdinReader: strings.NewReader(stdin),
+			StdinSHA256: hex.EncodeToString(sum[:]),
+			Payload:     map[string]interface{}{"rows": []int{1, 2, 3}},
+			Files: []pyExecuter.InputFile{
+				{Name: "data/config.txt", Data: []byte("debug=1")},
+				{Name: "source.txt", Path: source},
+			},
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"stdin":   float64(len(stdin)),
+			"payload": map[string]interface{}{"rows": []interface{}{float64(1), float64(2), float64(3)}},
+			"config":  "debug=1",
+			"source":  "from disk",
+		}, res.Value)
+
+		// 没有输入的任务读取到空的标准输入与负载
+		res, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "import sys, pyexecuter\nprint(repr(sys.stdin.read()), pyexecuter.payload())", Timeout: 10 * time.Second})
+		assert.NoError(t, err)
+		assert.Equal(t, "'' None\n", res.Stdout)
+	}
+
+	// 摘要不一致、超过大小限制或文件名无效的输入在执行前被拒绝
+	executor := &pyExecuter.SecurePythonExecutor{Venvs: venvs, InputLimits: pyExecuter.InputLimits{MaxFileBytes: 4}}
+	var inputErr *pyExecuter.InputError
+	_, err := executor.Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Input: &pyExecuter.TaskInput{Stdin: []byte("abc"), StdinSHA256: hex.EncodeToString(sum[:])}})
+	assert.ErrorIs(t, err, pyExecuter.ErrChecksumMismatch)
+	assert.True(t, errors.As(err, &inputErr))
+	assert.Equal(t, "stdin", inputErr.Input)
+	_, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Input: &pyExecuter.TaskInput{StdinReader: strings.NewReader("abc"), StdinSHA256: hex.EncodeToString(sum[:])}})
+	assert.ErrorIs(t, err, pyExecuter.ErrChecksumMismatch)
+	_, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Input: &pyExecuter.TaskInput{Files: []pyExecuter.InputFile{{Name: "big.txt", Data: []byte("too large")}}}})
+	assert.ErrorIs(t, err, pyExecuter.ErrInputTooLarge)
+	_, err = executor.Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Input: &pyExecuter.TaskInput{Files: []pyExecuter.InputFile{{Name: "../escape.txt", Data: []byte("x")}}}})
+	assert.True(t, errors.As(err, &inputErr))
+
+	// GopoolExecutor 在提交时即拒绝无效的输入
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(10, "FIFO"))
+	gopoolExecutor.InputLimits = pyExecuter.InputLimits{MaxPayloadBytes: 8}
+	err = gopoolExecutor.AddTask(&pyExecuter.Task{ID: "large-payload", Script: "print(1)", Input: &pyExecuter.TaskInput{Payload: json.RawMessage(`{"key": "value"}`)}})
+	assert.ErrorIs(t, err, pyExecuter.ErrInputTooLarge)
+}
+
+func TestTaskArtifacts(t *testing.T) {
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	store := pyExecuter.NewLocalArtifactStore(t.TempDir())
+	limits := pyExecuter.ArtifactLimits{MaxFileBytes: 1024}
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	pooled.Artifacts, pooled.ArtifactLimits = store, limits
+	defer pooled.Close()
+
+	script := `import os
+os.makedirs("out/nested")
+open("plot.png", "wb").write(b"png")
+open("out/nested/data.csv", "w").write("a,b\n1,2\n")
+open("notes.txt", "w").write("not collected")
+open("big.bin", "wb").write(b"x" * 4096)
+`
+	executors := []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs, Artifacts: store, ArtifactLimits: limits}, pooled}
+	for i, executor := range executors {
+		id := fmt.Sprintf("artifacts-%d", i)
+		res, err := executor.Run(context.Background(), &pyExecuter.Task{ID: id, Script: script, Outputs: []string{"*.png", "out/**", "big.bin"}, Timeout: 10 * time.Second})
+
+		// 超过大小限制的产物被跳过，其余产物照常收集
+		var artifactErr *pyExecuter.ArtifactError
+		assert.True(t, errors.As(err, &artifactErr))
+		assert.ErrorIs(t, err, pyExecuter.ErrArtifactTooLarge)
+		assert.Equal(t, []string{"big.bin"}, artifactErr.Skipped)
+		assert.Equal(t, 0, res.ExitCode)
+
+		assert.Len(t, res.Artifacts, 2)
+		sum := sha256.Sum256([]byte("a,b\n1,2\n"))
+		assert.Equal(t, "out/nested/data.csv", res.Artifacts[0].Name)
+		assert.Equal(t, int64(8), res.Artifacts[0].Size)
+		assert.Equal(t, hex.EncodeToString(sum[:]), res.Artifacts[0].SHA256)
+		assert.Equal(t, "plot.png", res.Artifacts[1].Name)
+
+		// 产物在临时目录删除后仍可从存储中读取
+		r, err := store.Open(context.Background(), res.Artifacts[1].Location)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, "png", string(data))
+		assert.Equal(t, filepath.Join(store.Root, id, "plot.png"), res.Artifacts[1].Location)
+	}
+
+	// 无效的匹配模式或未配置存储时任务被拒绝
+	_, err := executors[0].Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Outputs: []string{"../*.txt"}})
+	assert.Error(t, err)
+	_, err = (&pyExecuter.SecurePythonExecutor{Venvs: venvs}).Run(context.Background(), &pyExecuter.Task{Script: "print(1)", Outputs: []string{"*.txt"}})
+	assert.ErrorContains(t, err, "no artifact store")
+}
+
+func TestScriptSources(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"pkg/__init__.py": "",
+		"pkg/cli.py": `import sys
+
+def main():
+    print("main", sys.argv[1:])
+    return 3
+
+def fail():
+    raise ValueError("bad input")
+
+if __name__ == "__main__":
+    print("module", sys.argv[1:])
+`,
+		"tools/helper.py": "NAME = 'helper'\n",
+		"tools/tool.py":   "import sys, helper\nprint(helper.NAME, sys.argv[1:])\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	writeZip := func(name string, entries map[string]string) string {
+		path := filepath.Join(root, name)
+		f, err := os.Create(path)
+		assert.NoError(t, err)
+		w := zip.NewWriter(f)
+		for entry, content := range entries {
+			fw, _ := w.Create(entry)
+			fw.Write([]byte(content))
+		}
+		assert.NoError(t, w.Close())
+		f.Close()
+		return path
+	}
+	archive := writeZip("app.pyz", map[string]string{"__main__.py": "import sys, lib\nprint(lib.VALUE, sys.argv[1:])\n", "lib.py": "VALUE = 'archive'\n"})
+	library := writeZip("lib.zip", map[string]string{"zipped/__init__.py": "VALUE = 'zipped'\n"})
+
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		run := func(task pyExecuter.Task) (*pyExecuter.ExecutionResult, error) {
+			task.Args, task.Timeout = []string{"a"}, 10*time.Second
+			return executor.Run(context.Background(), &task)
+		}
+
+		// 调用函数的入口点以返回值作为退出码
+		res, err := run(pyExecuter.Task{EntryPoint: "pkg.cli:main", PythonPath: []string{root}})
+		assert.Error(t, err)
+		assert.Equal(t, 3, res.ExitCode)
+		assert.Equal(t, "main ['a']\n", res.Stdout)
+
+		res, err = run(pyExecuter.Task{EntryPoint: "pkg.cli", PythonPath: []string{root}})
+		assert.NoError(t, err)
+		assert.Equal(t, "module ['a']\n", res.Stdout)
+
+		// 模块中的异常帧保留真实路径，不包含启动脚本的帧
+		_, err = run(pyExecuter.Task{EntryPoint: "pkg.cli:fail", PythonPath: []string{root}})
+		var pyErr *pyExecuter.PythonError
+		assert.True(t, errors.As(err, &pyErr))
+		assert.Equal(t, "ValueError", pyErr.Type)
+		assert.Equal(t, filepath.Join(root, "pkg", "cli.py"), pyErr.Frames[0].File)
+		assert.Equal(t, "fail", pyErr.Frames[0].Function)
+		assert.NotContains(t, pyErr.Traceback, "bootstrap")
+
+		// 脚本文件可以导入同目录的模块
+		res, err = run(pyExecuter.Task{ScriptPath: filepath.Join(root, "tools", "tool.py")})
+		assert.NoError(t, err)
+		assert.Equal(t, "helper ['a']\n", res.Stdout)
+
+		res, err = run(pyExecuter.Task{Archive: archive})
+		assert.NoError(t, err)
+		assert.Equal(t, "archive ['a']\n", res.Stdout)
+
+		res, err = run(pyExecuter.Task{Script: "import zipped, pyexecuter\nprint(zipped.VALUE)", PythonPath: []string{library}})
+		assert.NoError(t, err)
+		assert.Equal(t, "zipped\n", res.Stdout)
+	}
+
+	// 无效的引用在提交时即被拒绝，不会进入队列
+	queue := pyExecuter.NewTaskQueue(10, "FIFO")
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, queue)
+	for _, task := range []*pyExecuter.Task{
+		{ID: "missing-file", ScriptPath: filepath.Join(root, "missing.py")},
+		{ID: "missing-module", EntryPoint: "pkg.missing:main", PythonPath: []string{root}},
+		{ID: "bad-entry-point", EntryPoint: "pkg.cli:"},
+		{ID: "not-an-archive", Archive: filepath.Join(root, "tools", "tool.py")},
+		{ID: "bad-path", Script: "print(1)", PythonPath: []string{filepath.Join(root, "missing")}},
+		{ID: "two-sources", Script: "print(1)", EntryPoint: "pkg.cli"},
+	} {
+		assert.Error(t, gopoolExecutor.AddTask(task), task.ID)
+	}
+	assert.NoError(t, gopoolExecutor.AddTask(&pyExecuter.Task{ID: "entry-point", EntryPoint: "pkg.cli:main", PythonPath: []string{root}}))
+	assert.Equal(t, 1, queue.Size())
+}
+
+func TestTaskEnvironment(t *testing.T) {
+	t.Setenv("PYEXECUTER_TEST_HOST_SECRET", "host-secret")
+	t.Setenv("LC_PYEXECUTER_TEST", "allowed")
+	workDir := t.TempDir()
+
+	script := `import json, os
+print(json.dumps({
+    "host": os.environ.get("PYEXECUTER_TEST_HOST_SECRET"),
+    "lc": os.environ.get("LC_PYEXECUTER_TEST"),
+    "task": os.environ.get("TASK_VAR"),
+    "token": os.environ.get("API_TOKEN"),
+    "venv": os.environ.get("VIRTUAL_ENV"),
+    "path": os.environ["PATH"].split(os.pathsep)[0],
+    "cwd": os.getcwd(),
+}))
+print("token is", os.environ.get("API_TOKEN"))
+`
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		run := func(task pyExecuter.Task) (map[string]interface{}, *pyExecuter.ExecutionResult) {
+			task.Script, task.Timeout = script, 10*time.Second
+			res, err := executor.Run(context.Background(), &task)
+			assert.NoError(t, err)
+			var env map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(strings.SplitN(res.Stdout, "\n", 2)[0]), &env))
+			return env, res
+		}
+
+		// 默认只继承允许列表中的变量，虚拟环境的变量总是被设置
+		env, res := run(pyExecuter.Task{Env: map[string]string{"TASK_VAR": "value"}, SecretEnv: map[string]string{"API_TOKEN": "s3cr3t-token"}, WorkDir: workDir})
+		assert.Nil(t, env["host"])
+		assert.Equal(t, "allowed", env["lc"])
+		assert.Equal(t, "value", env["task"])
+		assert.Equal(t, "s3cr3t-token", env["token"])
+		assert.NotEmpty(t, env["venv"])
+		assert.Equal(t, filepath.Join(env["venv"].(string), "bin"), env["path"])
+		assert.Equal(t, workDir, env["cwd"])
+
+		env, _ = run(pyExecuter.Task{EnvInherit: pyExecuter.InheritNone})
+		assert.Nil(t, env["lc"])
+		assert.NotEmpty(t, env["venv"])
+
+		env, _ = run(pyExecuter.Task{EnvInherit: pyExecuter.InheritAll})
+		assert.Equal(t, "host-secret", env["host"])
+		assert.Contains(t, res.Stdout, "token is s3cr3t-token")
+	}
+
+	// 任务执行期间，库写入的日志只使用该任务的敏感值脱敏
+	logFile := filepath.Join(t.TempDir(), "tasks.log")
+	logger := pyExecuter.NewFileLogger(logFile)
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(10, "FIFO"))
+	result := gopoolExecutor.ExecuteTask(&pyExecuter.Task{
+		ID: "secret-task", Script: script, Timeout: 10 * time.Second,
+		SecretEnv: map[string]string{"API_TOKEN": "s3cr3t-token"},
+		OnCompletion: func(r pyExecuter.Result) {
+			assert.NoError(t, logger.LogTaskStart(r.TaskID, r.StartTime))
+			assert.NoError(t, logger.LogTaskEnd(r.TaskID, r.EndTime, r.Output, errors.New("failed with s3cr3t-token")))
+			assert.Equal(t, "s3cr3t-token", pyExecuter.RedactTaskSecrets("other-task", "s3cr3t-token"))
+		},
+	})
+	assert.NoError(t, result.Error)
+	data, _ := os.ReadFile(logFile)
+	assert.Contains(t, string(data), "token is [REDACTED]")
+	assert.NotContains(t, string(data), "s3cr3t-token")
+	logs, _ := logger.FetchLogs("secret-task")
+	assert.Equal(t, "failed with [REDACTED]", logs[0].Error)
+	// 任务结束后其敏感值保留到调用 ReleaseTaskSecrets
+	assert.Equal(t, "[REDACTED]", pyExecuter.RedactTaskSecrets("secret-task", "s3cr3t-token"))
+	pyExecuter.ReleaseTaskSecrets("secret-task")
+	assert.Equal(t, "s3cr3t-token", pyExecuter.RedactTaskSecrets("secret-task", "s3cr3t-token"))
+
+	// 执行器返回后记录的日志同样被脱敏
+	task := &pyExecuter.Task{ID: "logged-task", Script: script, Timeout: 10 * time.Second, SecretEnv: map[string]string{"API_TOKEN": "s3cr3t-token"}}
+	res, err := (&pyExecuter.SecurePythonExecutor{Venvs: venvs}).Run(context.Background(), task)
+	assert.NoError(t, err)
+	assert.NoError(t, logger.LogTaskStart(task.ID, time.Now()))
+	assert.NoError(t, logger.LogTaskEnd(task.ID, time.Now(), res.Stdout, errors.New("failed with s3cr3t-token")))
+	pyExecuter.ReleaseTaskSecrets(task.ID)
+	data, _ = os.ReadFile(logFile)
+	assert.Contains(t, string(data), "Task logged-task ended")
+	assert.NotContains(t, string(data), "s3cr3t-token")
+	logs, _ = logger.FetchLogs(task.ID)
+	assert.Equal(t, "failed with [REDACTED]", logs[0].Error)
+
+	// 无效的变量名、过短的敏感值与不存在的工作目录在提交时被拒绝
+	assert.Error(t, gopoolExecutor.AddTask(&pyExecuter.Task{ID: "bad-env", Script: "print(1)", Env: map[string]string{"A=B": "c"}}))
+	assert.Error(t, gopoolExecutor.AddTask(&pyExecuter.Task{ID: "short-secret", Script: "print(1)", SecretEnv: map[string]string{"FLAG": "true"}}))
+	assert.Error(t, gopoolExecutor.AddTask(&pyExecuter.Task{ID: "bad-dir", Script: "print(1)", WorkDir: filepath.Join(workDir, "missing")}))
+}
+
+func TestOutputLimits(t *testing.T) {
+	venvs := pyExecuter.NewVenvManager(t.TempDir())
+	pooled := pyExecuter.NewPooledPythonExecutor(venvs, 1)
+	defer pooled.Close()
+
+	script := `import sys
+sys.stdout.write("a" * 10 + "b" * 1000 + "c" * 10)
+sys.stderr.write("e" * 50)`
+	for _, executor := range []pyExecuter.PythonExecutor{&pyExecuter.SecurePythonExecutor{Venvs: venvs}, pooled} {
+		for policy, want := range map[pyExecuter.OutputPolicy]string{
+			pyExecuter.OutputKeepHead:     strings.Repeat("a", 10) + strings.Repeat("b", 10),
+			pyExecuter.OutputKeepTail:     strings.Repeat("b", 10) + strings.Repeat("c", 10),
+			pyExecuter.OutputKeepHeadTail: strings.Repeat("a", 10) + "\n[... 1000 bytes truncated ...]\n" + strings.Repeat("c", 10),
+		} {
+			res, err := executor.Run(context.Background(), &pyExecuter.Task{Script: script, Timeout: 10 * time.Second, OutputLimits: &pyExecuter.OutputLimits{StdoutBytes: 20, Policy: policy}})
+			assert.NoError(t, err)
+			assert.Equal(t, want, res.Stdout, policy.String())
+			assert.True(t, res.StdoutTruncated)
+			assert.Equal(t, int64(1020), res.StdoutBytes)
+			assert.Equal(t, strings.Repeat("e", 50), res.Stderr)
+			assert.False(t, res.StderrTruncated)
+			assert.Equal(t, int64(50), res.StderrBytes)
+		}
+
+		// 超过上限时终止任务，而不是等到超时
+		start := time.Now()
+		res, err := executor.Run(context.Background(), &pyExecuter.Task{
+			Script:       "while True:\n    print('x' * 1000)",
+			Timeout:      30 * time.Second,
+			OutputLimits: &pyExecuter.OutputLimits{StdoutBytes: 1 << 20, Policy: pyExecuter.OutputKill},
+		})
+		assert.ErrorContains(t, err, "output limit")
+		assert.Equal(t, pyExecuter.LimitOutput, res.LimitExceeded)
+		assert.False(t, res.TimedOut)
+		assert.Len(t, res.Stdout, 1<<20)
+		assert.True(t, res.StdoutTruncated)
+		assert.Greater(t, res.StdoutBytes, int64(1<<20))
+		assert.Less(t, time.Since(start), 20*time.Second)
+
+		// 未设置上限时使用 DefaultOutputLimits，子进程的输出同样计入
+		res, err = executor.Run(context.Background(), &pyExecuter.Task{
+			Script:  "import os, subprocess\nsubprocess.run(['echo', 'child'])\nchunk = b'x' * (1 << 20)\nfor _ in range(64):\n    os.write(1, chunk)",
+			Timeout: 30 * time.Second,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6+64<<20), res.StdoutBytes)
+		assert.Len(t, res.Stdout, int(pyExecuter.DefaultOutputLimits.StdoutBytes))
+		assert.True(t, strings.HasPrefix(res.Stdout, "child\nxxx"))
+		assert.True(t, res.StdoutTruncated)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	executor := &pyExecuter.SecurePythonExecutor{Venvs: pyExecuter.NewVenvManager(t.TempDir())}
+	manager := pyExecuter.NewSessionManager(executor, 500*time.Millisecond)
+	defer manager.Close()
+
+	session, err := manager.Create(context.Background(), pyExecuter.SessionOptions{Env: map[string]string{"GREETING": "hi"}})
+	assert.NoError(t, err)
+
+	// 代码单元共享全局变量，最后一个表达式的值被返回
+	res, err := session.Run(context.Background(), "import os\nx = 20\nprint(os.environ['GREETING'])")
+	assert.NoError(t, err)
+	assert.Equal(t, "hi\n", res.Stdout)
+	assert.Equal(t, "", res.Repr)
+	assert.Equal(t, 1, res.ExecutionCount)
+	res, err = session.Run(context.Background(), "def f(y):\n    return {'x': x + y}\nf(22)")
+	assert.NoError(t, err)
+	assert.Equal(t, "{'x': 42}", res.Repr)
+	assert.Equal(t, map[string]interface{}{"x": float64(42)}, res.Value)
+	assert.Equal(t, 2, res.ExecutionCount)
+	res, err = session.Run(context.Background(), "object()")
+	assert.NoError(t, err)
+	assert.Contains(t, res.Repr, "<object object")
+	assert.Nil(t, res.Value)
+
+	// 异常不会结束会话
+	res, err = session.Run(context.Background(), "f('a')")
+	assert.Error(t, err)
+	if assert.NotNil(t, res.Exception) {
+		assert.Equal(t, "TypeError", res.Exception.Type)
+	}
+
+	// 中断只结束当前代码单元
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		session.Interrupt()
+	}()
+	res, err = session.Run(context.Background(), "import time\nwhile True:\n    time.sleep(0.1)")
+	assert.Error(t, err)
+	assert.True(t, res.Interrupted)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	res, err = session.Run(ctx, "time.sleep(30)")
+	cancel()
+	assert.ErrorContains(t, err, "timed out")
+	assert.True(t, res.Interrupted)
+	// 代码单元开始执行前到期的中断同样生效，会话不会被关闭
+	start := time.Now()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	res, err = session.Run(ctx, "time.sleep(30)")
+	cancel()
+	assert.ErrorContains(t, err, "timed out")
+	if assert.NotNil(t, res) {
+		assert.True(t, res.Interrupted)
+	}
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.False(t, session.Closed())
+	res, err = session.Run(context.Background(), "x")
+	assert.NoError(t, err)
+	assert.Equal(t, "20", res.Repr)
+
+	// 空闲超过 TTL 的会话被关闭
+	got, ok := manager.Get(session.ID)
+	assert.True(t, ok)
+	assert.Equal(t, session, got)
+	time.Sleep(1500 * time.Millisecond)
+	_, ok = manager.Get(session.ID)
+	assert.False(t, ok)
+	assert.True(t, session.Closed())
+	_, err = session.Run(context.Background(), "x")
+	assert.ErrorIs(t, err, pyExecuter.ErrSessionClosed)
+}
+
+func TestTaskHandles(t *testing.T) {
+	queue := pyExecuter.NewTaskQueue(100, "FIFO")
+	executor := pyExecuter.NewGopoolExecutor(2, queue)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, executor.Start(ctx))
+
+	h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "handle_ok", Script: "print('handle')", Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	res, err := h.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "handle\n", res.Stdout)
+	assert.Equal(t, pyExecuter.TaskSucceeded, h.Status())
+	assert.Equal(t, pyExecuter.TaskSucceeded, res.Status)
+	select {
+	case <-h.Done():
+	default:
+		t.Fatal("Done channel not closed")
+	}
+
+	// 重试耗尽后句柄才结束
+	attempts := 0
+	h, err = executor.Submit(context.Background(), &pyExecuter.Task{
+		ID:           "handle_fail",
+		Script:       "raise SystemExit(3)",
+		Timeout:      10 * time.Second,
+		RetryCount:   1,
+		OnCompletion: func(pyExecuter.Result) { attempts++ },
+	})
+	assert.NoError(t, err)
+	res, err = h.Wait(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, pyExecuter.TaskFailed, res.Status)
+	assert.Equal(t, 3, res.ExitCode)
+	assert.Equal(t, 2, attempts)
+
+	// 取消正在执行的任务
+	h, err = executor.Submit(context.Background(), &pyExecuter.Task{ID: "handle_cancel", Script: "import time\ntime.sleep(30)", Timeout: time.Minute})
+	assert.NoError(t, err)
+	for h.Status() != pyExecuter.TaskRunning {
+		time.Sleep(50 * time.Millisecond)
+	}
+	_, ok := h.Result()
+	assert.False(t, ok)
+	start := time.Now()
+	h.Cancel()
+	res, err = h.Wait(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, pyExecuter.TaskCancelled, res.Status)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	// 批量提交，等待全部完成或第一个错误
+	var tasks []*pyExecuter.Task
+	for i := 0; i < 4; i++ {
+		tasks = append(tasks, &pyExecuter.Task{ID: fmt.Sprintf("batch_%d", i), Script: fmt.Sprintf("print(%d)", i), Timeout: 10 * time.Second})
+	}
+	handles, err := executor.SubmitAll(context.Background(), tasks)
+	assert.NoError(t, err)
+	results, err := pyExecuter.WaitAll(context.Background(), handles)
+	assert.NoError(t, err)
+	for i, res := range results {
+		assert.Equal(t, fmt.Sprintf("%d\n", i), res.Stdout)
+	}
+	handles, err = executor.SubmitAll(context.Background(), []*pyExecuter.Task{
+		{ID: "batch_slow", Script: "import time\ntime.sleep(30)", Timeout: time.Minute},
+		{ID: "batch_bad", Script: "1/0", Timeout: 10 * time.Second},
+	})
+	assert.NoError(t, err)
+	_, err = pyExecuter.WaitAll(context.Background(), handles)
+	assert.ErrorContains(t, err, "ZeroDivisionError")
+	handles[0].Cancel()
+
+	// 校验失败时不提交任何任务
+	_, err = executor.SubmitAll(context.Background(), []*pyExecuter.Task{{ID: "valid", Script: "pass"}, {ID: "invalid", Script: "pass", ScriptPath: "x.py"}})
+	assert.Error(t, err)
+	assert.Equal(t, 0, queue.Size())
+}
+
+func TestCancelTask(t *testing.T) {
+	queue := pyExecuter.NewTaskQueue(100, "FIFO")
+	executor := pyExecuter.NewGopoolExecutor(2, queue)
+	monitor := pyExecuter.NewBasicTaskMonitor()
+	recovery := pyExecuter.NewTaskRecovery(t.TempDir())
+	executor.Monitor = monitor
+	executor.Recovery = recovery
+
+	var mu sync.Mutex
+	completions := make(map[string][]pyExecuter.TaskStatus)
+	onCompletion := func(res pyExecuter.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		completions[res.TaskID] = append(completions[res.TaskID], res.Status)
+	}
+
+	// 执行器启动前任务仍在队列中
+	queued, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "cancel_queued", Script: "print('never')", OnCompletion: onCompletion})
+	assert.NoError(t, err)
+	assert.NoError(t, executor.CancelTask("cancel_queued"))
+	res, err := queued.Wait(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, pyExecuter.TaskCancelled, res.Status)
+	assert.Equal(t, 0, queue.Size())
+	assert.Error(t, executor.CancelTask("cancel_queued"))
+	assert.Error(t, executor.CancelTask("missing"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		// 关闭时会写入 Recovery，需要在删除临时目录前完成
+		cancel()
+		<-executor.Stopped()
+	}()
+	assert.NoError(t, executor.Start(ctx))
+
+	// 终止正在执行的任务，且不会重试
+	running, err := executor.Submit(context.Background(), &pyExecuter.Task{
+		ID:           "cancel_running",
+		Script:       "import subprocess, time\nsubprocess.Popen(['sleep', '60'])\ntime.sleep(60)",
+		Timeout:      2 * time.Minute,
+		RetryCount:   3,
+		OnCompletion: onCompletion,
+	})
+	assert.NoError(t, err)
+	for running.Status() != pyExecuter.TaskRunning {
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+	start := time.Now()
+	assert.NoError(t, executor.CancelTask("cancel_running"))
+	res, err = running.Wait(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, pyExecuter.TaskCancelled, res.Status)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 0, queue.Size())
+
+	mu.Lock()
+	assert.Equal(t, []pyExecuter.TaskStatus{pyExecuter.TaskCancelled}, completions["cancel_queued"])
+	assert.Equal(t, []pyExecuter.TaskStatus{pyExecuter.TaskCancelled}, completions["cancel_running"])
+	mu.Unlock()
+	for _, id := range []string{"cancel_queued", "cancel_running"} {
+		status, err := monitor.GetTaskStatus(id)
+		assert.NoError(t, err)
+		assert.Equal(t, pyExecuter.MonitorStatusCancelled, status.Status)
+		state, err := recovery.RecoverTaskState(id)
+		assert.NoError(t, err)
+		assert.Equal(t, "cancelled", state.State)
+	}
+
+	// 未通过 Submit 提交的任务同样可以取消
+	assert.NoError(t, queue.AddTask(&pyExecuter.Task{ID: "cancel_plain", Script: "import time\ntime.sleep(60)", Timeout: time.Minute, OnCompletion: onCompletion}))
+	for {
+		if status, err := monitor.GetTaskStatus("cancel_plain"); err == nil && status.Status == pyExecuter.MonitorStatusRunning {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.NoError(t, executor.CancelTask("cancel_plain"))
+	for {
+		if state, err := recovery.RecoverTaskState("cancel_plain"); err == nil && state.State == "cancelled" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestEventDrivenDispatch(t *testing.T) {
+	// 队列为空时阻塞，加入任务后立即返回
+	queue := pyExecuter.NewTaskQueue(100, "FIFO")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	_, err := queue.WaitTask(ctx)
+	cancel()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		queue.AddTask(&pyExecuter.Task{ID: "wake"})
+	}()
+	task, err := queue.WaitTask(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "wake", task.ID)
+
+	executor := pyExecuter.NewGopoolExecutor(1, queue)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, executor.Start(ctx))
+
+	// 空闲的执行器立即开始新任务
+	time.Sleep(300 * time.Millisecond)
+	h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "fast", Script: "pass", Timeout: 10 * time.Second})
+	submitted := time.Now()
+	assert.NoError(t, err)
+	res, err := h.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, res.StartTime.Sub(submitted), 50*time.Millisecond)
+
+	// 池已满时任务留在队列中
+	slow, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "slow", Script: "import time\ntime.sleep(1)", Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	for slow.Status() != pyExecuter.TaskRunning {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var handles []*pyExecuter.TaskHandle
+	for i := 0; i < 3; i++ {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("waiting_%d", i), Script: "pass", Timeout: 10 * time.Second})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 3, queue.Size())
+	_, err = pyExecuter.WaitAll(context.Background(), append(handles, slow))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queue.Size())
+}
+
+func TestShutdown(t *testing.T) {
+	// 排空队列后关闭
+	executor := pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(100, "FIFO"))
+	executor.DrainOnShutdown = true
+	recovery := pyExecuter.NewTaskRecovery(t.TempDir())
+	executor.Recovery = recovery
+	assert.NoError(t, executor.Start(context.Background()))
+	var handles []*pyExecuter.TaskHandle
+	for i, script := range []string{"import time\ntime.sleep(0.5)", "pass", "pass"} {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("drain_%d", i), Script: script, Timeout: 10 * time.Second})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	report, err := executor.Shutdown(ctx)
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Succeeded)
+	assert.Empty(t, report.Interrupted)
+	assert.Empty(t, report.Abandoned)
+	assert.True(t, report.Persisted)
+	_, err = pyExecuter.WaitAll(context.Background(), handles)
+	assert.NoError(t, err)
+	pending, err := recovery.PendingTasks()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	_, err = executor.Submit(context.Background(), &pyExecuter.Task{ID: "late", Script: "pass"})
+	assert.ErrorIs(t, err, pyExecuter.ErrExecutorClosed)
+	select {
+	case <-executor.Stopped():
+	default:
+		t.Fatal("Stopped channel not closed")
+	}
+
+	// 超过期限后终止正在执行的任务，未完成的任务被保存
+	recoveryDir := t.TempDir()
+	recovery = pyExecuter.NewTaskRecovery(recoveryDir)
+	executor = pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(100, "FIFO"))
+	executor.Recovery = recovery
+	assert.NoError(t, executor.Start(context.Background()))
+	long, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "long", Script: "import time\ntime.sleep(60)", Timeout: time.Minute, RetryCount: 2})
+	assert.NoError(t, err)
+	for long.Status() != pyExecuter.TaskRunning {
+		time.Sleep(20 * time.Millisecond)
+	}
+	queued, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "queued", Script: "print('later')", Timeout: 10 * time.Second, SecretEnv: map[string]string{"API_TOKEN": "s3cr3t-token"}})
+	assert.NoError(t, err)
+	ctx, cancel = context.WithTimeout(context.Background(), 300*time.Millisecond)
+	start := time.Now()
+	report, err = executor.Shutdown(ctx)
+	cancel()
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, []string{"long"}, report.Interrupted)
+	assert.Equal(t, []string{"queued"}, report.Abandoned)
+	assert.Equal(t, 2, report.Cancelled)
+	for _, h := range []*pyExecuter.TaskHandle{long, queued} {
+		res, err := h.Wait(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, pyExecuter.TaskCancelled, res.Status)
+	}
+
+	// 重启后取回未完成的任务继续执行
+	pending, err = recovery.PendingTasks()
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, "long", pending[0].ID)
+		assert.Equal(t, 2, pending[0].RetryCount)
+		assert.Equal(t, time.Minute, pending[0].Timeout)
+		assert.Equal(t, "queued", pending[1].ID)
+		// 敏感值不会写入文件，需要在重新提交前设置
+		assert.Nil(t, pending[1].SecretEnv)
+	}
+	saved, err := os.ReadFile(filepath.Join(recoveryDir, "pending_tasks.json"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(saved), "s3cr3t-token")
+	executor = pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(100, "FIFO"))
+	startCtx, stop := context.WithCancel(context.Background())
+	assert.NoError(t, executor.Start(startCtx))
+	h, err := executor.Submit(context.Background(), pending[1])
+	assert.NoError(t, err)
+	res, err := h.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "later\n", res.Stdout)
+
+	// Start 的 ctx 结束时立即关闭
+	stop()
+	select {
+	case <-executor.Stopped():
+	case <-time.After(10 * time.Second):
+		t.Fatal("executor did not stop")
+	}
+	report, err = executor.Shutdown(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Succeeded)
+}
+
+func TestPauseResize(t *testing.T) {
+	queue := pyExecuter.NewTaskQueue(100, "FIFO")
+	executor := pyExecuter.NewGopoolExecutor(1, queue)
+	var mu sync.Mutex
+	var events []pyExecuter.ExecutorEventType
+	executor.OnEvent = func(event pyExecuter.ExecutorEvent) {
+		mu.Lock()
+		events = append(events, event.Type)
+		mu.Unlock()
+	}
+	assert.Equal(t, "created", executor.GetStats()["state"])
+	assert.NoError(t, executor.Start(context.Background()))
+	assert.Equal(t, "running", executor.GetStats()["state"])
+
+	// 暂停后正在执行的任务继续执行，新任务留在队列中
+	running, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "before_pause", Script: "import time\ntime.sleep(0.5)", Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	for running.Status() != pyExecuter.TaskRunning {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, executor.Pause())
+	assert.NoError(t, executor.Pause())
+	assert.True(t, executor.Paused())
+	var handles []*pyExecuter.TaskHandle
+	for i := 0; i < 3; i++ {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("paused_%d", i), Script: "import time\ntime.sleep(0.5)", Timeout: 10 * time.Second})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	_, err = running.Wait(context.Background())
+	assert.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	stats := executor.GetStats()
+	assert.Equal(t, "paused", stats["state"])
+	assert.Equal(t, true, stats["paused"])
+	assert.Equal(t, 3, stats["queue_size"])
+	assert.Equal(t, 0, stats["active_tasks"])
+	for _, h := range handles {
+		assert.Equal(t, pyExecuter.TaskQueued, h.Status())
+	}
+
+	// 扩大池后恢复，排队的任务并行执行
+	assert.Error(t, executor.Resize(0))
+	assert.NoError(t, executor.Resize(3))
+	assert.Equal(t, 3, executor.GetStats()["pool_size"])
+	start := time.Now()
+	assert.NoError(t, executor.Resume())
+	_, err = pyExecuter.WaitAll(context.Background(), handles)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 1200*time.Millisecond)
+
+	// 缩小池后超出的任务等待名额
+	assert.NoError(t, executor.Resize(1))
+	handles = handles[:0]
+	for i := 0; i < 2; i++ {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("shrunk_%d", i), Script: "import time\ntime.sleep(0.3)", Timeout: 10 * time.Second})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, 1, executor.GetStats()["active_tasks"])
+	results, err := pyExecuter.WaitAll(context.Background(), handles)
+	assert.NoError(t, err)
+	assert.True(t, !results[1].StartTime.Before(results[0].EndTime) || !results[0].StartTime.Before(results[1].EndTime))
+
+	_, err = executor.Shutdown(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "stopped", executor.GetStats()["state"])
+	assert.ErrorIs(t, executor.Pause(), pyExecuter.ErrExecutorClosed)
+	assert.ErrorIs(t, executor.Resize(2), pyExecuter.ErrExecutorClosed)
+	mu.Lock()
+	assert.Equal(t, []pyExecuter.ExecutorEventType{
+		pyExecuter.EventStarted, pyExecuter.EventPaused, pyExecuter.EventResized, pyExecuter.EventResumed,
+		pyExecuter.EventResized, pyExecuter.EventShuttingDown, pyExecuter.EventStopped,
+	}, events)
+	mu.Unlock()
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	load, err := pyExecuter.SampleHostLoad()
+	assert.NoError(t, err)
+	assert.Greater(t, load.CPUs, 0)
+	assert.Greater(t, load.MemTotalBytes, uint64(0))
+	assert.LessOrEqual(t, load.MemAvailableBytes, load.MemTotalBytes)
+
+	idle := pyExecuter.HostLoad{CPUs: 4, Load1: 0.5, MemTotalBytes: 8 << 30, MemAvailableBytes: 6 << 30, PSI: true}
+	busy := idle
+	busy.Load1 = 8
+	lowMemory := idle
+	lowMemory.MemAvailableBytes = 256 << 20
+
+	executor := pyExecuter.NewGopoolExecutor(2, pyExecuter.NewTaskQueue(100, "FIFO"))
+	assert.NoError(t, executor.Start(context.Background()))
+	defer executor.Shutdown(context.Background())
+	current := idle
+	controller := pyExecuter.NewAdaptiveConcurrency(executor, 1, 3)
+	controller.Sampler = func() (pyExecuter.HostLoad, error) {
+		return current, nil
+	}
+	var decisions []pyExecuter.ConcurrencyDecision
+	controller.OnDecision = func(d pyExecuter.ConcurrencyDecision) {
+		decisions = append(decisions, d)
+	}
+
+	// 空闲时增加并发数直到上限，过载时减少
+	assert.Equal(t, 3, controller.Step().Concurrency)
+	assert.Equal(t, 3, controller.Step().Concurrency)
+	current = busy
+	d := controller.Step()
+	assert.Equal(t, 3, d.Previous)
+	assert.Equal(t, 2, d.Concurrency)
+	assert.Equal(t, "cpu overloaded", d.Reason)
+	assert.Equal(t, 2, executor.GetStats()["pool_size"])
+	assert.Len(t, decisions, 3)
+	assert.Equal(t, d, controller.Last())
+
+	// 内存不足时暂缓内存密集型任务，其他任务照常执行
+	current = lowMemory
+	d = controller.Step()
+	assert.True(t, d.HoldMemoryHeavy)
+	assert.Equal(t, 1, d.Concurrency)
+	heavy, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "heavy", Script: "print('heavy')", Timeout: 10 * time.Second, Resources: &pyExecuter.Resources{MemoryMB: 2048}})
+	assert.NoError(t, err)
+	light, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "light", Script: "print('light')", Timeout: 10 * time.Second})
+	assert.NoError(t, err)
+	_, err = light.Wait(context.Background())
+	assert.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, pyExecuter.TaskQueued, heavy.Status())
+
+	current = idle
+	assert.False(t, controller.Step().HoldMemoryHeavy)
+	res, err := heavy.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "heavy\n", res.Stdout)
+
+	// 采样失败时保持并发数
+	controller.Sampler = func() (pyExecuter.HostLoad, error) {
+		return pyExecuter.HostLoad{}, fmt.Errorf("no load")
+	}
+	d = controller.Step()
+	assert.Error(t, d.Err)
+	assert.Equal(t, d.Previous, d.Concurrency)
+
+	// 自定义控制算法，Run 在 ctx 结束时返回
+	controller.Sampler = nil
+	controller.Policy = fixedPolicy(1)
+	controller.Interval = 20 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, controller.Run(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 1, executor.GetStats()["pool_size"])
+	assert.Error(t, pyExecuter.NewAdaptiveConcurrency(executor, 3, 2).Run(context.Background()))
+}
+
+// fixedPolicy 总是返回固定并发数的控制算法
+type fixedPolicy int
+
+func (p fixedPolicy) Decide(current int, load pyExecuter.HostLoad) pyExecuter.ConcurrencyDecision {
+	return pyExecuter.ConcurrencyDecision{Concurrency: int(p), Reason: "fixed"}
+}
+
+func TestResourceAdmission(t *testing.T) {
+	executor := pyExecuter.NewGopoolExecutor(4, pyExecuter.NewTaskQueue(100, "FIFO"))
+	executor.Capacity = &pyExecuter.Resources{CPU: 2, MemoryMB: 4096, Custom: map[string]int{"gpu-license": 1}}
+	executor.MaxBypass = 2
+	assert.NoError(t, executor.Start(context.Background()))
+	defer executor.Shutdown(context.Background())
+
+	// 超过容量或未知的资源在提交时被拒绝
+	for _, resources := range []*pyExecuter.Resources{
+		{CPU: 4},
+		{MemoryMB: 8192},
+		{Custom: map[string]int{"gpu-license": 2}},
+		{Custom: map[string]int{"fpga": 1}},
+		{CPU: -1},
+		{CPU: math.NaN()},
+		{CPU: math.Inf(1)},
+	} {
+		_, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "too_big", Script: "pass", Resources: resources})
+		assert.Error(t, err)
+	}
+
+	// 独占的具名资源不会被同时使用
+	var handles []*pyExecuter.TaskHandle
+	for i := 0; i < 2; i++ {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("gpu_%d", i), Script: "import time\ntime.sleep(0.3)", Timeout: 10 * time.Second, Resources: &pyExecuter.Resources{Custom: map[string]int{"gpu-license": 1}}})
+		assert.NoError(t, err)
+		handles = append(handles, h)
+	}
+	results, err := pyExecuter.WaitAll(context.Background(), handles)
+	assert.NoError(t, err)
+	assert.True(t, !results[1].StartTime.Before(results[0].EndTime) || !results[0].StartTime.Before(results[1].EndTime))
+
+	// 资源不足的任务被较小的任务越过，越过 MaxBypass 次后不再被越过
+	hog, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "hog", Script: "import time\ntime.sleep(1.5)", Timeout: 10 * time.Second, Resources: &pyExecuter.Resources{CPU: 1.5}})
+	assert.NoError(t, err)
+	for hog.Status() != pyExecuter.TaskRunning {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1.5, executor.GetStats()["reserved"].(pyExecuter.Resources).CPU)
+	large, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: "large", Script: "pass", Timeout: 10 * time.Second, Priority: 10, Resources: &pyExecuter.Resources{CPU: 1, MemoryMB: 1024}})
+	assert.NoError(t, err)
+	var smalls []*pyExecuter.TaskHandle
+	for i := 0; i < 4; i++ {
+		h, err := executor.Submit(context.Background(), &pyExecuter.Task{ID: fmt.Sprintf("small_%d", i), Script: "import time\ntime.sleep(0.3)", Timeout: 10 * time.Second, Priority: 1, Resources: &pyExecuter.Resources{CPU: 0.5}})
+		assert.NoError(t, err)
+		smalls = append(smalls, h)
+	}
+	results, err = pyExecuter.WaitAll(context.Background(), append(smalls, large, hog))
+	assert.NoError(t, err)
+	largeResult, hogResult := results[4], results[5]
+	assert.False(t, largeResult.StartTime.Before(hogResult.EndTime))
+	bypassed := 0
+	for _, res := range results[:4] {
+		if res.StartTime.Before(hogResult.EndTime) {
+			bypassed++
+		}
+	}
+	assert.Equal(t, 2, bypassed)
+
+	reserved := executor.GetStats()["reserved"].(pyExecuter.Resources)
+	assert.Zero(t, reserved.CPU)
+	assert.Zero(t, reserved.MemoryMB)
+	assert.Empty(t, reserved.Custom)
+}
+
+func TestVenvManager(t *testing.T) {
+	root := t.TempDir()
+	manager := pyExecuter.NewVenvManager(root)
+	manager.MaxEnvs = 1
+
+	// 并发请求同一环境时只构建一次
+	venvs := make([]*pyExecuter.Venv, 3)
+	errs := make([]error, 3)
+	var wg sync.WaitGroup
+	for i := range venvs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			venvs[i], errs[i] = manager.Acquire(context.Background(), pyExecuter.EnvSpec{})
+		}(i)
+	}
+	wg.Wait()
+	for i := range venvs {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, venvs[0].Dir, venvs[i].Dir)
+	}
+	assert.True(t, strings.HasPrefix(venvs[0].Dir, root))
+	assert.FileExists(t, venvs[0].Python())
+
+	// 使用中的环境不会被淘汰
+	other, err := manager.Acquire(context.Background(), pyExecuter.EnvSpec{Dependencies: "requests"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, venvs[0].Dir, other.Dir)
+	assert.DirExists(t, venvs[0].Dir)
+
+	// 归还后按LRU淘汰最久未使用的环境
+	for _, venv := range venvs {
+		venv.Release()
+	}
+	other.Release()
+	_, err = os.Stat(venvs[0].Dir)
+	assert.True(t, os.IsNotExist(err))
+	assert.DirExists(t, other.Dir)
+
+	// 安装选项不同的环境不共享
+	indexed, err := manager.Acquire(context.Background(), pyExecuter.EnvSpec{Dependencies: "requests", Options: "allow-index=true"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, other.Dir, indexed.Dir)
+	indexed.Release()
+
+	// 共享 Root 的其他实例在本实例归还后使用过的环境，淘汰时只从缓存中移除
+	shared := pyExecuter.NewVenvManager(root)
+	reused, err := shared.Acquire(context.Background(), pyExecuter.EnvSpec{Dependencies: "requests", Options: "allow-index=true"})
+	assert.NoError(t, err)
+	assert.Equal(t, indexed.Dir, reused.Dir)
+	reused.Release()
+	next, err := manager.Acquire(context.Background(), pyExecuter.EnvSpec{Dependencies: "numpy"})
+	assert.NoError(t, err)
+	next.Release()
+	assert.DirExists(t, indexed.Dir)
+
+	// 构建锁被其他进程持有的环境同样保留
+	assert.NoError(t, os.Mkdir(next.Dir+".lock", 0755))
+	last, err := manager.Acquire(context.Background(), pyExecuter.EnvSpec{Dependencies: "pandas"})
+	assert.NoError(t, err)
+	last.Release()
+	assert.DirExists(t, next.Dir)
+	assert.NoError(t, os.Remove(next.Dir+".lock"))
+}
+
+// writeWheel 在 dir 中生成一个只包含单个模块的最小 wheel 包
+func writeWheel(t *testing.T, dir, name, version, source string) {
+	f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%s-%s-py3-none-any.whl", name, version)))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	distInfo := fmt.Sprintf("%s-%s.dist-info", name, version)
+	files := map[string]string{
+		name + ".py":            source,
+		distInfo + "/METADATA": fmt.Sprintf("Metadata-Version: 2.1\nName: %s\nVersion: %s\n", name, version),
+		distInfo + "/WHEEL":    "Wheel-Version: 1.0\nGenerator: test\nRoot-Is-Purelib: true\nTag: py3-none-any\n",
+	}
+	w := zip.NewWriter(f)
+	var record strings.Builder
+	for path, content := range files {
+		fw, err := w.Create(path)
+		assert.NoError(t, err)
+		fw.Write([]byte(content))
+		record.WriteString(path + ",,\n")
+	}
+	fw, err := w.Create(distInfo + "/RECORD")
+	assert.NoError(t, err)
+	fw.Write([]byte(record.String() + distInfo + "/RECORD,,\n"))
+	assert.NoError(t, w.Close())
+}
+
+func TestTaskDependencies(t *testing.T) {
+	wheelhouse := t.TempDir()
+	writeWheel(t, wheelhouse, "hello_dep", "1.0", "GREETING = 'hello from wheel'\n")
+
+	executor := &pyExecuter.SecurePythonExecutor{
+		Venvs: pyExecuter.NewVenvManager(t.TempDir()),
+		Pip:   pyExecuter.PipOptions{Wheelhouse: wheelhouse},
+	}
+
+	// 从本地 wheelhouse 离线安装依赖
+	task := &pyExecuter.Task{
+		Script:       "import hello_dep\nprint(hello_dep.GREETING)",
+		Dependencies: &pyExecuter.DependencySpec{RequirementsTxt: "# test\nhello_dep==1.0\n"},
+		Timeout:      10 * time.Second,
+	}
+	res, err := executor.Run(context.Background(), task)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello from wheel\n", res.Stdout)
+
+	// 安装失败应返回 DependencyError，而不是脚本执行错误
+	task.Dependencies = &pyExecuter.DependencySpec{Requirements: []string{"no_such_package_xyz"}}
+	_, err = executor.Run(context.Background(), task)
+	var depErr *pyExecuter.DependencyError
+	assert.True(t, errors.As(err, &depErr))
+	assert.Contains(t, depErr.Output, "no_such_package_xyz")
+
+	// 只去除行首或空白之后的注释，URL 中的片段保留
+	task.Dependencies = &pyExecuter.DependencySpec{RequirementsTxt: "no_such_package_xyz @ file:///nonexistent/pkg.whl#sha256=00  # pinned\n"}
+	_, err = executor.Run(context.Background(), task)
+	if assert.True(t, errors.As(err, &depErr)) {
+		assert.Equal(t, "no_such_package_xyz @ file:///nonexistent/pkg.whl#sha256=00", depErr.Requirements)
+	}
+
+	// RequirementsTxt 原样交给 pip，续行中的 --hash 作用于其前面的依赖
+	wheel, err := os.ReadFile(filepath.Join(wheelhouse, "hello_dep-1.0-py3-none-any.whl"))
+	assert.NoError(t, err)
+	sum := sha256.Sum256(wheel)
+	task.Dependencies = &pyExecuter.DependencySpec{RequirementsTxt: "hello_dep==1.0 \\\n    --hash=sha256:" + hex.EncodeToString(sum[:]) + "\n"}
+	res, err = executor.Run(context.Background(), task)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello from wheel\n", res.Stdout)
+	}
+	task.Dependencies = &pyExecuter.DependencySpec{RequirementsTxt: "hello_dep==1.0 \\\n    --hash=sha256:" + strings.Repeat("0", 64) + "\n"}
+	_, err = executor.Run(context.Background(), task)
+	if assert.True(t, errors.As(err, &depErr)) {
+		assert.Contains(t, depErr.Output, "HASHES")
+	}
+
+	// 固定环境被所有任务共享，任务不能声明依赖
+	shared := &pyExecuter.SecurePythonExecutor{Environment: t.TempDir()}
+	_, err = shared.Run(context.Background(), task)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &depErr))
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, pyExecuter.NewTaskQueue(10, "FIFO"))
+	gopoolExecutor.Executor = shared
+	assert.Error(t, gopoolExecutor.AddTask(task))
+}
+
+func TestInterpreterSelection(t *testing.T) {
+	registry := pyExecuter.NewInterpreterRegistry()
+	interpreters := registry.Discover()
+	if len(interpreters) == 0 {
+		t.Skip("no python interpreter found on host")
+	}
+
+	// 按版本约束选择解释器，结果中记录实际版本
+	target := interpreters[len(interpreters)-1]
+	parts := strings.SplitN(target.Version, ".", 3)
+	constraint := parts[0] + "." + parts[1]
+
+	executor := &pyExecuter.SecurePythonExecutor{
+		Venvs:        pyExecuter.NewVenvManager(t.TempDir()),
+		Interpreters: registry,
+	}
+	task := &pyExecuter.Task{
+		Script:      "import platform\nprint(platform.python_version())",
+		Interpreter: pyExecuter.InterpreterSelector{Version: constraint},
+		Timeout:     10 * time.Second,
+	}
+	res, err := executor.Run(context.Background(), task)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(res.PythonVersion, constraint))
+	assert.Equal(t, res.PythonVersion+"\n", res.Stdout)
+
+	// 找不到匹配的解释器时任务在入队前被拒绝
+	queue := pyExecuter.NewTaskQueue(10, "FIFO")
+	gopoolExecutor := pyExecuter.NewGopoolExecutor(1, queue)
+	err = gopoolExecutor.AddTask(&pyExecuter.Task{
+		ID:          "old_python",
+		Interpreter: pyExecuter.InterpreterSelector{Version: "<2.0"},
+	})
+	assert.ErrorIs(t, err, pyExecuter.ErrNoInterpreter)
+	assert.Equal(t, 0, queue.Size())
+
+	// 配置了执行器时由该执行器校验，使用固定环境的执行器不限制任务的解释器
+	gopoolExecutor.Executor = &pyExecuter.SecurePythonExecutor{Environment: t.TempDir()}
+	assert.NoError(t, gopoolExecutor.AddTask(&pyExecuter.Task{
+		ID:          "fixed_env",
+		Script:      "pass",
+		Interpreter: pyExecuter.InterpreterSelector{Version: "<2.0"},
+	}))
+
+	// 排序运算符比较完整版本
+	if len(parts) == 3 && parts[2] != "0" {
+		_, err = registry.Resolve(pyExecuter.InterpreterSelector{Path: target.Path, Version: ">" + constraint})
+		assert.NoError(t, err)
+		_, err = registry.Resolve(pyExecuter.InterpreterSelector{Path: target.Path, Version: "<=" + constraint})
+		assert.ErrorIs(t, err, pyExecuter.ErrNoInterpreter)
+	}
+
+	// 原地升级解释器后重新查询版本
+	fake := filepath.Join(t.TempDir(), "python3")
+	assert.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\necho 3.9.1\n"), 0755))
+	version, err := registry.Version(fake)
+	assert.NoError(t, err)
+	assert.Equal(t, "3.9.1", version)
+	assert.NoError(t, os.WriteFile(fake, []byte("#!/bin/sh\necho 3.12.10\n"), 0755))
+	version, err = registry.Version(fake)
+	assert.NoError(t, err)
+	assert.Equal(t, "3.12.10", version)
+}
+
 func TestTaskQueue(t *testing.T) {
 	queue := pyExecuter.NewTaskQueue(10, "FIFO")
 
@@ -0,0 +1,392 @@
+package pyExecuter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// venvReadyMarker 虚拟环境构建完成后写入的标记文件，其修改时间记录最近使用时间
+const venvReadyMarker = ".pyexecuter-ready"
+
+// staleLockAge 构建锁超过该时长仍未释放时视为构建进程已崩溃
+const staleLockAge = 30 * time.Minute
+
+// EnvSpec 描述虚拟环境的内容，解释器的路径与版本、依赖描述及安装选项共同决定缓存键
+type EnvSpec struct {
+	Interpreter  string                                         // 用于创建虚拟环境的Python解释器，默认为 "python"
+	Dependencies string                                         // 依赖描述，参与缓存键计算
+	Options      string                                         // 影响环境内容的安装选项（如 pip 的索引配置），参与缓存键计算
+	Provision    func(ctx context.Context, envDir string) error // 环境创建后的初始化操作（如安装依赖），不参与缓存键计算
+}
+
+// Venv 一个已就绪、正在被使用的虚拟环境
+type Venv struct {
+	Key           string // 缓存键
+	Dir           string // 虚拟环境目录
+	PythonVersion string // 创建环境所用解释器的版本
+
+	release func()
+	once    sync.Once
+}
+
+// Python 返回虚拟环境中Python解释器的路径
+func (v *Venv) Python() string {
+	return filepath.Join(v.Dir, "bin", "python")
+}
+
+// Release 归还虚拟环境，归还后的环境才可能被淘汰
+func (v *Venv) Release() {
+	v.once.Do(v.release)
+}
+
+// venvEntry 记录缓存中的一个虚拟环境
+type venvEntry struct {
+	dir      string
+	version  string
+	size     int64
+	lastUsed time.Time
+	refs     int
+}
+
+// VenvManager 管理按内容寻址、可在任务间复用的Python虚拟环境
+// 环境按解释器的路径与版本、依赖描述及安装选项计算缓存键，超出数量或磁盘配额时按LRU淘汰未被使用的环境
+type VenvManager struct {
+	Root     string // 虚拟环境的存储根目录
+	MaxEnvs  int    // 最多保留的环境数量，0表示不限制
+	MaxBytes int64  // 所有环境可占用的磁盘空间（字节），0表示不限制
+	// Interpreters 查询解释器版本所用的注册表，为 nil 时使用默认实例
+	Interpreters *InterpreterRegistry
+
+	envs     map[string]*venvEntry
+	building map[string]chan struct{}
+	scanned  bool
+	mu       sync.Mutex
+}
+
+// DefaultVenvRoot 返回默认的虚拟环境存储根目录
+func DefaultVenvRoot() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "pyexecuter", "venvs")
+	}
+	return filepath.Join(os.TempDir(), "pyexecuter-venvs")
+}
+
+// NewVenvManager 创建 VenvManager 实例，root 为空时使用 DefaultVenvRoot
+func NewVenvManager(root string) *VenvManager {
+	if root == "" {
+		root = DefaultVenvRoot()
+	}
+	return &VenvManager{
+		Root:     root,
+		envs:     make(map[string]*venvEntry),
+		building: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire 获取符合 spec 的虚拟环境，不存在时创建
+// 多个任务同时请求同一环境时只会构建一次，使用完毕后必须调用 Venv.Release
+func (m *VenvManager) Acquire(ctx context.Context, spec EnvSpec) (*Venv, error) {
+	interpreter := spec.Interpreter
+	if interpreter == "" {
+		interpreter = "python"
+	}
+	registry := m.Interpreters
+	if registry == nil {
+		registry = defaultInterpreters
+	}
+	version, err := registry.Version(interpreter)
+	if err != nil {
+		return nil, err
+	}
+	key := venvKey(interpreterPath(interpreter), version, spec.Dependencies, spec.Options)
+
+	if err := m.scan(); err != nil {
+		return nil, err
+	}
+
+	for {
+		m.mu.Lock()
+		if entry, ok := m.envs[key]; ok {
+			entry.refs++
+			entry.lastUsed = time.Now()
+			m.mu.Unlock()
+			touchMarker(entry.dir)
+			return m.newVenv(key, entry), nil
+		}
+		if done, ok := m.building[key]; ok {
+			// 其他任务正在构建同一环境，等待其完成后重新检查
+			m.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		m.building[key] = done
+		m.mu.Unlock()
+
+		entry, err := m.build(ctx, key, interpreter, version, spec)
+
+		m.mu.Lock()
+		delete(m.building, key)
+		close(done)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		entry.refs++
+		m.envs[key] = entry
+		victims := m.evictLocked()
+		m.mu.Unlock()
+
+		removeEnvs(victims)
+		return m.newVenv(key, entry), nil
+	}
+}
+
+// newVenv 为缓存项创建 Venv 句柄
+func (m *VenvManager) newVenv(key string, entry *venvEntry) *Venv {
+	return &Venv{
+		Key:           key,
+		Dir:           entry.dir,
+		PythonVersion: entry.version,
+		release: func() {
+			m.mu.Lock()
+			entry.refs--
+			entry.lastUsed = time.Now()
+			victims := m.evictLocked()
+			m.mu.Unlock()
+
+			removeEnvs(victims)
+		},
+	}
+}
+
+// build 构建虚拟环境（内部方法）
+// 通过锁目录保证多个进程不会同时构建同一环境
+func (m *VenvManager) build(ctx context.Context, key, interpreter, version string, spec EnvSpec) (*venvEntry, error) {
+	dir := filepath.Join(m.Root, key)
+	unlock, err := lockDir(ctx, dir+".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock virtual environment %s: %v", key, err)
+	}
+	defer unlock()
+
+	// 其他进程可能已经完成了构建
+	if _, err := os.Stat(filepath.Join(dir, venvReadyMarker)); err == nil {
+		touchMarker(dir)
+		return &venvEntry{dir: dir, version: version, size: dirSize(dir), lastUsed: time.Now()}, nil
+	}
+
+	// 清理上次中断的构建
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clean virtual environment %s: %v", key, err)
+	}
+
+	cmd := exec.CommandContext(ctx, interpreter, "-m", "venv", dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create virtual environment: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if spec.Provision != nil {
+		if err := spec.Provision(ctx, dir); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, venvReadyMarker), []byte(version+"\n"), 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to mark virtual environment ready: %v", err)
+	}
+
+	return &venvEntry{dir: dir, version: version, size: dirSize(dir), lastUsed: time.Now()}, nil
+}
+
+// evictLocked 按LRU选出需要淘汰的环境并从缓存中移除，返回待删除的环境（调用方需持有锁）
+func (m *VenvManager) evictLocked() []*venvEntry {
+	var victims []*venvEntry
+	for m.overQuotaLocked() {
+		var oldestKey string
+		var oldest *venvEntry
+		for key, entry := range m.envs {
+			if entry.refs > 0 {
+				continue
+			}
+			if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		if oldest == nil {
+			// 所有环境都在使用中，暂时无法淘汰
+			break
+		}
+		delete(m.envs, oldestKey)
+		victims = append(victims, oldest)
+	}
+	return victims
+}
+
+// removeEnvs 删除被淘汰的环境目录
+// 删除前获取与构建相同的跨进程锁，锁被占用或标记文件的修改时间晚于本实例记录的最近使用时间时，
+// 说明共享 Root 的其他进程正在构建或刚使用过该环境，此时只从缓存中移除而保留目录
+func removeEnvs(victims []*venvEntry) {
+	for _, entry := range victims {
+		unlock := tryLockDir(entry.dir + ".lock")
+		if unlock == nil {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(entry.dir, venvReadyMarker))
+		if err != nil || !info.ModTime().After(entry.lastUsed) {
+			os.RemoveAll(entry.dir)
+		}
+		unlock()
+	}
+}
+
+// overQuotaLocked 判断缓存是否超出数量或磁盘配额（调用方需持有锁）
+func (m *VenvManager) overQuotaLocked() bool {
+	if m.MaxEnvs > 0 && len(m.envs) > m.MaxEnvs {
+		return true
+	}
+	if m.MaxBytes > 0 {
+		var total int64
+		for _, entry := range m.envs {
+			total += entry.size
+		}
+		return total > m.MaxBytes
+	}
+	return false
+}
+
+// scan 首次使用时加载根目录中已存在的环境
+func (m *VenvManager) scan() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.scanned {
+		return nil
+	}
+	if err := os.MkdirAll(m.Root, 0755); err != nil {
+		return fmt.Errorf("failed to create virtual environment root: %v", err)
+	}
+	entries, err := os.ReadDir(m.Root)
+	if err != nil {
+		return fmt.Errorf("failed to read virtual environment root: %v", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasSuffix(e.Name(), ".lock") {
+			continue
+		}
+		dir := filepath.Join(m.Root, e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, venvReadyMarker))
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, venvReadyMarker))
+		if err != nil {
+			continue
+		}
+		m.envs[e.Name()] = &venvEntry{
+			dir:      dir,
+			version:  strings.TrimSpace(string(data)),
+			size:     dirSize(dir),
+			lastUsed: info.ModTime(),
+		}
+	}
+	m.scanned = true
+	return nil
+}
+
+// venvKey 根据解释器的路径与版本、依赖描述及安装选项计算缓存键
+func venvKey(interpreter, version, dependencies, options string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{interpreter, version, dependencies, options}, "\x00")))
+	return hex.EncodeToString(sum[:])[:32]
+}
+
+// interpreterPath 返回解释器的绝对路径，按 PATH 查找；无法解析时原样返回
+func interpreterPath(interpreter string) string {
+	path, err := exec.LookPath(interpreter)
+	if err != nil {
+		return interpreter
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// lockDir 通过创建目录获取跨进程的锁，返回解锁函数
+func lockDir(ctx context.Context, path string) (func(), error) {
+	for {
+		err := os.Mkdir(path, 0755)
+		if err == nil {
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if stale(path) {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// tryLockDir 尝试获取 lockDir 的锁而不等待，锁已被持有时返回 nil
+func tryLockDir(path string) func() {
+	if err := os.Mkdir(path, 0755); err != nil {
+		if !os.IsExist(err) || !stale(path) {
+			return nil
+		}
+		os.Remove(path)
+		if os.Mkdir(path, 0755) != nil {
+			return nil
+		}
+	}
+	return func() { os.Remove(path) }
+}
+
+// stale 判断锁目录是否超过 staleLockAge 仍未释放
+func stale(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > staleLockAge
+}
+
+// touchMarker 更新标记文件的修改时间，用于在重启后恢复LRU顺序
+func touchMarker(dir string) {
+	now := time.Now()
+	os.Chtimes(filepath.Join(dir, venvReadyMarker), now, now)
+}
+
+// dirSize 计算目录占用的字节数
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,456 @@
+"""pyExecuter 常驻 worker：通过管道接收脚本，在全新的命名空间中执行并返回结果。
+
+请求从文件描述符 3 读取，响应写入文件描述符 4，每条消息为 4 字节大端长度前缀加 JSON。
+启动后两个描述符会被移到高位并关闭原编号，脚本不会因沿用约定的编号而写入协议管道。
+请求: {"script": str, "args": [str], "input": {"dir": str, "stdin": str, "payload": str} | None,
+       "cwd": str | None, "env": {str: str}, "output": {"stdout": int, "stderr": int, "policy": str} | None,
+       "stream": bool}
+响应: {"stdout": str, "stderr": str, "stdout_bytes": int, "stderr_bytes": int,
+       "exit_code": int, "cpu": float, "rss": int, "result": str | None, "leftover": bool}
+输出超过 output 中的上限时按 policy（head、tail 或 head_tail）截断，stdout_bytes 与 stderr_bytes 为实际字节数。
+其中 result 为脚本通过 pyexecuter.set_result 写入的原始 JSON，
+响应中的 exception 为未捕获异常的结构化描述，没有异常时为 None。
+请求的 stream 为 true 时，输出在产生时即以 {"stream": "stdout" | "stderr", "data": str} 消息发送，其后才是响应。
+任务结束后仍有存活的线程或进程时响应的 leftover 为 true，调用方应回收 worker。
+
+以 --session 参数启动时 worker 处于会话模式：请求中的 script 为代码单元，在共享的命名空间中执行，
+env、args、cwd 与 input 被忽略，环境变量与当前目录在代码单元之间保留；响应另外包含
+execution_count、最后一个表达式的 repr 及其 JSON 编码 value（无法编码时为 None）。
+"""
+import ast
+import atexit
+import builtins
+import codecs
+import fcntl
+import json
+import linecache
+import os
+import resource
+import select
+import shutil
+import signal
+import struct
+import sys
+import tempfile
+import threading
+import time
+import traceback
+import types
+
+
+def _read(f):
+    header = f.read(4)
+    if len(header) < 4:
+        return None
+    (size,) = struct.unpack(">I", header)
+    return json.loads(f.read(size))
+
+
+_write_lock = threading.Lock()
+
+
+def _write(f, message):
+    data = json.dumps(message).encode()
+    with _write_lock:
+        f.write(struct.pack(">I", len(data)) + data)
+        f.flush()
+
+
+def _rss():
+    """返回当前常驻内存（字节）。"""
+    try:
+        with open("/proc/self/statm") as f:
+            return int(f.read().split()[1]) * os.sysconf("SC_PAGE_SIZE")
+    except (OSError, ValueError):
+        return resource.getrusage(resource.RUSAGE_SELF).ru_maxrss * 1024
+
+
+def _cpu():
+    """返回 worker 及其已回收子进程累计使用的 CPU 时间（秒）。"""
+    total = 0.0
+    for who in (resource.RUSAGE_SELF, resource.RUSAGE_CHILDREN):
+        usage = resource.getrusage(who)
+        total += usage.ru_utime + usage.ru_stime
+    return total
+
+
+def _exit_code(code):
+    """按解释器的规则将 SystemExit 的参数转换为退出码。"""
+    if code is None:
+        return 0
+    if isinstance(code, int):
+        return code & 0xFF
+    print(code, file=sys.stderr)
+    return 1
+
+
+class _Sink:
+    """按上限与策略保存输出，超过上限的部分直接丢弃，同时统计实际写入的字节数。"""
+
+    def __init__(self, limit=0, policy="head"):
+        self.limit = limit
+        self.policy = policy
+        if not limit or policy == "head":
+            self.head_max = limit
+        elif policy == "tail":
+            self.head_max = 0
+        else:
+            self.head_max = limit // 2
+        self.tail_max = limit - self.head_max if limit else 0
+        self.head = bytearray()
+        self.tail = bytearray()
+        self.size = 0
+
+    def write(self, data):
+        self.size += len(data)
+        if not self.limit:
+            self.head += data
+            return
+        if len(self.head) < self.head_max:
+            n = self.head_max - len(self.head)
+            self.head += data[:n]
+            data = data[n:]
+        if data and self.tail_max:
+            self.tail += data
+            del self.tail[: -self.tail_max]
+
+    def text(self):
+        """返回保存的输出，保留开头与结尾时在两者之间插入截断说明。"""
+        data = bytes(self.head)
+        if self.policy == "head_tail" and self.limit and self.size > self.limit:
+            marker = "\n[... %d bytes truncated ...]\n" % (self.size - len(self.head) - len(self.tail))
+            data += marker.encode()
+        data += bytes(self.tail)
+        return data.decode("utf-8", errors="replace")
+
+
+class _Capture:
+    """在文件描述符级别重定向标准输出与标准错误，子进程与C扩展的输出也会被收集。
+
+    输出经由管道读取，只保存请求中上限以内的部分，脚本写出大量输出时 worker 的内存与磁盘占用仍然有界。
+    请求要求流式输出时，读取到的全部输出还会通过 emit(stream, data) 立即发送。
+    """
+
+    def __init__(self, request, emit=None):
+        output = request.get("output") or {}
+        policy = output.get("policy", "head")
+        self.sinks = _Sink(output.get("stdout", 0), policy), _Sink(output.get("stderr", 0), policy)
+        self.emit = emit if request.get("stream") else None
+
+    def __enter__(self):
+        sys.stdout.flush()
+        sys.stderr.flush()
+        self.stopping = threading.Event()
+        self.saved_fds = os.dup(1), os.dup(2)
+        self.readers = []
+        for fd, sink, name in zip((1, 2), self.sinks, ("stdout", "stderr")):
+            r, w = os.pipe()
+            os.set_inheritable(r, False)
+            os.dup2(w, fd)
+            os.close(w)
+            reader = threading.Thread(target=self._drain, args=(r, sink, name), daemon=True)
+            reader.start()
+            self.readers.append(reader)
+        return self
+
+    def _drain(self, fd, sink, name):
+        """读取管道直到所有写端关闭；任务结束后管道仍被残留的后代进程持有时，最多再读取 0.1 秒。"""
+        # 数据块可能在多字节字符的中间切开，按流增量解码后再发送
+        decoder = codecs.getincrementaldecoder("utf-8")("replace") if self.emit else None
+        poller = select.poll()
+        poller.register(fd, select.POLLIN)
+        deadline = None
+        try:
+            while deadline is None or time.monotonic() < deadline:
+                if deadline is None and self.stopping.is_set():
+                    deadline = time.monotonic() + 0.1
+                if not poller.poll(50):
+                    if deadline is not None:
+                        return
+                    continue
+                data = os.read(fd, 65536)
+                if not data:
+                    return
+                sink.write(data)
+                if decoder:
+                    self._emit(name, decoder.decode(data))
+        finally:
+            if decoder:
+                self._emit(name, decoder.decode(b"", True))
+            os.close(fd)
+
+    def _emit(self, name, text):
+        if text:
+            self.emit(name, text)
+
+    def __exit__(self, *exc):
+        sys.stdout.flush()
+        sys.stderr.flush()
+        os.dup2(self.saved_fds[0], 1)
+        os.dup2(self.saved_fds[1], 2)
+        for fd in self.saved_fds:
+            os.close(fd)
+        self.stopping.set()
+        for reader in self.readers:
+            reader.join()
+        return False
+
+    def response(self):
+        """返回响应中的输出字段。"""
+        stdout, stderr = self.sinks
+        return {
+            "stdout": stdout.text(),
+            "stderr": stderr.text(),
+            "stdout_bytes": stdout.size,
+            "stderr_bytes": stderr.size,
+        }
+
+
+def _report(e, filename):
+    """打印未捕获异常的回溯并返回其结构化描述，回溯从 filename 的第一帧开始。"""
+    import pyexecuter
+
+    tb = pyexecuter._strip_traceback(e.__traceback__, filename)
+    traceback.print_exception(type(e), e, tb)
+    return pyexecuter._describe_exception(e, tb)
+
+
+def _read_result(result_dir):
+    """读取脚本写入结果通道的原始数据并删除临时目录。"""
+    try:
+        with open(os.path.join(result_dir, "result.json"), encoding="utf-8", errors="replace") as f:
+            return f.read()
+    except FileNotFoundError:
+        return None
+    finally:
+        shutil.rmtree(result_dir, ignore_errors=True)
+
+
+def _run_exitfuncs():
+    """执行并清除脚本注册的 atexit 回调，与脚本独立运行时在退出前执行回调的行为一致。"""
+    run = getattr(atexit, "_run_exitfuncs", None)
+    if run is None or not atexit._ncallbacks():
+        return
+    run()
+    atexit._clear()
+
+
+def _has_leftovers():
+    """判断任务是否留下了仍在运行的线程或进程，同时回收已退出的子进程。
+
+    检查主线程以外的存活线程、未退出的子进程，以及同一进程组中的其他进程（需要 /proc）。
+    """
+    if any(t is not threading.main_thread() and t.is_alive() for t in threading.enumerate()):
+        return True
+    try:
+        while True:
+            pid, _ = os.waitpid(-1, os.WNOHANG)
+            if pid == 0:
+                return True
+    except ChildProcessError:
+        pass
+    pgid = str(os.getpgrp())
+    try:
+        entries = os.listdir("/proc")
+    except OSError:
+        return False
+    for name in entries:
+        if not name.isdigit() or int(name) == os.getpid():
+            continue
+        try:
+            with open("/proc/%s/stat" % name) as f:
+                stat = f.read()
+        except OSError:
+            continue
+        # 格式为 "pid (comm) state ppid pgrp ..."，comm 中可能包含空格
+        fields = stat[stat.rfind(")") + 1 :].split()
+        if len(fields) >= 3 and fields[0] not in ("Z", "X") and fields[2] == pgid:
+            return True
+    return False
+
+
+def _run(request, emit):
+    """在全新的 __main__ 命名空间中执行脚本，结束后恢复解释器的全局状态。
+
+    脚本写入任务的临时目录，__file__ 指向该文件；脚本注册的 atexit 回调在任务结束时执行。
+    """
+    saved_main = sys.modules["__main__"]
+    saved_argv = sys.argv
+    saved_path = list(sys.path)
+    saved_environ = dict(os.environ)
+    saved_cwd = os.getcwd()
+    saved_stdin = sys.stdin
+    saved_stdin_fd = None
+    result_dir = tempfile.mkdtemp(prefix="pyexecuter_result_")
+
+    start = _cpu()
+    exit_code = 0
+    exception = None
+    with _Capture(request, emit) as capture:
+        try:
+            module = types.ModuleType("__main__")
+            module.__file__ = os.path.join(result_dir, "script.py")
+            with open(module.__file__, "w", encoding="utf-8") as f:
+                f.write(request["script"])
+            sys.modules["__main__"] = module
+            os.environ.clear()
+            os.environ.update(request.get("env") or saved_environ)
+            os.environ["PYEXECUTER_RESULT_PATH"] = os.path.join(result_dir, "result.json")
+            sys.argv = ["<script>"] + (request.get("args") or [])
+            if request.get("cwd"):
+                os.chdir(request["cwd"])
+            inputs = request.get("input")
+            if inputs:
+                os.environ["PYEXECUTER_INPUT_DIR"] = inputs["dir"]
+                os.environ["PYEXECUTER_PAYLOAD_PATH"] = inputs["payload"]
+                if inputs.get("stdin"):
+                    saved_stdin_fd = os.dup(0)
+                    fd = os.open(inputs["stdin"], os.O_RDONLY)
+                    os.dup2(fd, 0)
+                    os.close(fd)
+                    sys.stdin = open(0, "r", closefd=False)
+            exec(compile(request["script"], "<script>", "exec"), module.__dict__)
+        except SystemExit as e:
+            exit_code = _exit_code(e.code)
+        except BaseException as e:
+            exception = _report(e, "<script>")
+            exit_code = 1
+        finally:
+            _run_exitfuncs()
+            if saved_stdin_fd is not None:
+                os.dup2(saved_stdin_fd, 0)
+                os.close(saved_stdin_fd)
+            sys.stdin = saved_stdin
+            sys.modules["__main__"] = saved_main
+            sys.argv = saved_argv
+            sys.path[:] = saved_path
+            os.environ.clear()
+            os.environ.update(saved_environ)
+            os.chdir(saved_cwd)
+
+    response = capture.response()
+    response.update(
+        exit_code=exit_code,
+        cpu=_cpu() - start,
+        result=_read_result(result_dir),
+        exception=exception,
+        leftover=_has_leftovers(),
+    )
+    return response
+
+
+class _Session:
+    """会话模式的解释器状态：所有代码单元共享同一个 __main__ 命名空间。
+
+    SIGINT 只在代码单元执行期间转换为 KeyboardInterrupt，空闲时收到的中断被忽略，
+    因此中断只会结束当前的代码单元，不会结束 worker。代码单元开始执行前会发送 {"started": true}，
+    调用方收到后才发送 SIGINT；通知发出后、进入执行前收到的中断会在开始执行时立即生效。
+    """
+
+    def __init__(self):
+        self.module = types.ModuleType("__main__")
+        self.module.__dict__["__builtins__"] = builtins
+        sys.modules["__main__"] = self.module
+        sys.argv = [""]
+        self.count = 0
+        self.running = False
+        self.pending = False
+        signal.signal(signal.SIGINT, self._interrupt)
+
+    def _interrupt(self, signum, frame):
+        if self.running:
+            raise KeyboardInterrupt
+        self.pending = True
+
+    def run(self, request, started):
+        """执行一个代码单元，最后一条语句是表达式时返回其值的 repr 与 JSON 编码。"""
+        self.count += 1
+        filename = "<cell-%d>" % self.count
+        source = request["script"]
+        # 让回溯与 inspect 能显示代码单元的源码
+        linecache.cache[filename] = (len(source), None, source.splitlines(True), filename)
+        namespace = self.module.__dict__
+        result_dir = tempfile.mkdtemp(prefix="pyexecuter_result_")
+        os.environ["PYEXECUTER_RESULT_PATH"] = os.path.join(result_dir, "result.json")
+
+        start = _cpu()
+        exit_code = 0
+        exception = None
+        value_repr = None
+        value_json = None
+        with _Capture(request) as capture:
+            try:
+                tree = ast.parse(source, filename)
+                last = None
+                if tree.body and isinstance(tree.body[-1], ast.Expr):
+                    last = ast.Expression(tree.body.pop().value)
+                value = None
+                self.pending = False
+                started()
+                try:
+                    self.running = True
+                    if self.pending:
+                        raise KeyboardInterrupt
+                    exec(compile(tree, filename, "exec"), namespace)
+                    if last is not None:
+                        value = eval(compile(last, filename, "eval"), namespace)
+                finally:
+                    self.running = False
+                if value is not None:
+                    namespace["_"] = value
+                    value_repr = repr(value)
+                    try:
+                        value_json = json.dumps(value, allow_nan=False)
+                    except (TypeError, ValueError, RecursionError):
+                        pass
+            except SystemExit as e:
+                exit_code = _exit_code(e.code)
+            except BaseException as e:
+                exception = _report(e, filename)
+                exit_code = 1
+            finally:
+                os.environ.pop("PYEXECUTER_RESULT_PATH", None)
+
+        response = capture.response()
+        response.update(
+            exit_code=exit_code,
+            cpu=_cpu() - start,
+            result=_read_result(result_dir),
+            exception=exception,
+            execution_count=self.count,
+            repr=value_repr,
+            value=value_json,
+        )
+        return response
+
+
+def _move_fd(fd):
+    """将描述符复制到高位（不可继承）并关闭原描述符，返回新的描述符。"""
+    soft, _ = resource.getrlimit(resource.RLIMIT_NOFILE)
+    if soft == resource.RLIM_INFINITY or soft > 1024:
+        soft = 1024
+    new = fcntl.fcntl(fd, fcntl.F_DUPFD_CLOEXEC, max(soft - 64, 10))
+    os.close(fd)
+    return new
+
+
+def main():
+    # 协议管道不留在约定的编号上，脚本启动的子进程也不会继承
+    requests = os.fdopen(_move_fd(3), "rb")
+    responses = os.fdopen(_move_fd(4), "wb")
+    session = _Session() if "--session" in sys.argv[1:] else None
+
+    while True:
+        request = _read(requests)
+        if request is None:
+            return
+        if session:
+            response = session.run(request, lambda: _write(responses, {"started": True}))
+        else:
+            response = _run(request, lambda stream, data: _write(responses, {"stream": stream, "data": data}))
+        response["rss"] = _rss()
+        _write(responses, response)
+
+
+if __name__ == "__main__":
+    main()